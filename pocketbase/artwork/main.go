@@ -0,0 +1,97 @@
+package artwork
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"slices"
+
+	"github.com/disintegration/imaging"
+	"github.com/wader/goutubedl"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// MinSize and MaxSize are the artwork sizes podcast apps accept.
+	MinSize  = 1400
+	MaxSize  = 3000
+	maxBytes = 20 << 20
+)
+
+// Candidates lists the video's thumbnail URLs, largest first. The hqdefault
+// JPEG comes last since every video has one.
+func Candidates(info goutubedl.Info) []string {
+	thumbnails := slices.Clone(info.Thumbnails)
+	slices.SortStableFunc(thumbnails, func(a, b goutubedl.Thumbnail) int {
+		return b.Width*b.Height - a.Width*a.Height
+	})
+
+	urls := []string{}
+	for _, t := range thumbnails {
+		if t.URL != "" && t.Width > 0 {
+			urls = append(urls, t.URL)
+		}
+	}
+	if info.Thumbnail != "" {
+		urls = append(urls, info.Thumbnail)
+	}
+	if info.ID != "" {
+		urls = append(urls, "https://i.ytimg.com/vi/"+info.ID+"/hqdefault.jpg")
+	}
+	return urls
+}
+
+// Fetch downloads the first candidate that can be decoded.
+func Fetch(ctx context.Context, client *http.Client, urls []string) (image.Image, error) {
+	var lastErr error = fmt.Errorf("video has no thumbnails")
+	for _, url := range urls {
+		img, err := fetch(ctx, client, url)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return img, nil
+	}
+	return nil, lastErr
+}
+
+func fetch(ctx context.Context, client *http.Client, url string) (image.Image, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download thumbnail: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("thumbnail request failed with status %d", resp.StatusCode)
+	}
+
+	img, _, err := image.Decode(io.LimitReader(resp.Body, maxBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode thumbnail: %w", err)
+	}
+	return img, nil
+}
+
+// Square crops the image to a centered square and scales it to between
+// MinSize and MaxSize pixels a side, encoded as a JPEG.
+func Square(img image.Image) ([]byte, error) {
+	bounds := img.Bounds()
+	size := min(max(min(bounds.Dx(), bounds.Dy()), MinSize), MaxSize)
+
+	square := imaging.Fill(img, size, size, imaging.Center, imaging.Lanczos)
+
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, square, imaging.JPEG, imaging.JPEGQuality(85)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
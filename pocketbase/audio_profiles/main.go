@@ -0,0 +1,89 @@
+package audio_profiles
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+// Default is used by podcasts and jobs that don't pick a profile, and matches
+// what every download was encoded with before profiles existed.
+const Default = "music-192k-mp3"
+
+type Profile struct {
+	Name    string
+	Codec   string
+	Bitrate string
+	// Channels forces the channel layout, 0 keeps the source's.
+	Channels  int
+	Extension string
+	MimeType  string
+}
+
+var profiles = []Profile{
+	{Name: "speech-64k-mono-mp3", Codec: "libmp3lame", Bitrate: "64k", Channels: 1, Extension: "mp3", MimeType: "audio/mpeg"},
+	{Name: "music-192k-mp3", Codec: "libmp3lame", Bitrate: "192k", Extension: "mp3", MimeType: "audio/mpeg"},
+	{Name: "opus-48k", Codec: "libopus", Bitrate: "48k", Extension: "opus", MimeType: "audio/ogg"},
+	{Name: "aac-m4a", Codec: "aac", Bitrate: "128k", Extension: "m4a", MimeType: "audio/x-m4a"},
+}
+
+var mimeTypes = map[string]string{
+	".mp3":  "audio/mpeg",
+	".m4a":  "audio/x-m4a",
+	".opus": "audio/ogg",
+	".ogg":  "audio/ogg",
+	".aac":  "audio/aac",
+	".wav":  "audio/wav",
+}
+
+func Get(name string) (Profile, bool) {
+	for _, p := range profiles {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Profile{}, false
+}
+
+// Resolve returns the named profile, or the default one if the name is empty
+// or unknown.
+func Resolve(name string) Profile {
+	if p, ok := Get(name); ok {
+		return p
+	}
+	p, _ := Get(Default)
+	return p
+}
+
+func Names() []string {
+	names := make([]string, 0, len(profiles))
+	for _, p := range profiles {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// MimeType returns the audio MIME type for a file name, going by its
+// extension. Unknown extensions are assumed to be MP3.
+func MimeType(fileName string) string {
+	if mimeType, ok := mimeTypes[strings.ToLower(filepath.Ext(fileName))]; ok {
+		return mimeType
+	}
+	return "audio/mpeg"
+}
+
+// Transcode encodes src into dst with the profile's codec, bitrate and
+// channel layout, applying any processing on the way.
+func Transcode(src, dst string, p Profile, processing Processing) error {
+	args := ffmpeg.KwArgs{"vn": "", "acodec": p.Codec, "ab": p.Bitrate}
+	if p.Channels > 0 {
+		args["ac"] = strconv.Itoa(p.Channels)
+	}
+	if filters := processing.filters(); filters != "" {
+		args["af"] = filters
+	}
+
+	return ffmpeg.Input(src).Output(dst, args).OverWriteOutput().ErrorToStdOut().Run()
+}
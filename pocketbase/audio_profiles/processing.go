@@ -0,0 +1,135 @@
+package audio_profiles
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/lsherman98/yt-rss/pocketbase/sponsorblock"
+)
+
+// Speeds are the playback speeds that can be baked into a file.
+var Speeds = []float64{1.25, 1.5}
+
+// Processing is the optional clean-up applied while a file is encoded. The
+// zero value leaves the audio untouched.
+type Processing struct {
+	// LoudnessTarget is the EBU R128 integrated loudness in LUFS, 0 skips
+	// normalization.
+	LoudnessTarget float64
+	TrimSilence    bool
+	// Speed is the tempo multiplier, 0 or 1 keeps the original speed.
+	Speed float64
+	// SkipCategories are the SponsorBlock categories to cut. Cuts holds the
+	// segments found for the video being encoded; it isn't part of the key
+	// since it is looked up at encode time.
+	SkipCategories []string
+	Cuts           []sponsorblock.Segment
+}
+
+func (p Processing) IsZero() bool {
+	return p.LoudnessTarget == 0 && !p.TrimSilence && (p.Speed == 0 || p.Speed == 1) && len(p.SkipCategories) == 0
+}
+
+// CutLength is how many seconds of the source the cuts remove.
+func (p Processing) CutLength() float64 {
+	total := 0.0
+	for _, cut := range p.Cuts {
+		total += cut.End - cut.Start
+	}
+	return total
+}
+
+func (p Processing) Validate() error {
+	if p.LoudnessTarget != 0 && (p.LoudnessTarget < -70 || p.LoudnessTarget > -5) {
+		return fmt.Errorf("loudness target must be between -70 and -5 LUFS")
+	}
+
+	if p.Speed != 0 && p.Speed != 1 {
+		valid := false
+		for _, speed := range Speeds {
+			if p.Speed == speed {
+				valid = true
+			}
+		}
+		if !valid {
+			return fmt.Errorf("speed must be one of 1.25 or 1.5")
+		}
+	}
+
+	for _, category := range p.SkipCategories {
+		if !slices.Contains(sponsorblock.Categories, category) {
+			return fmt.Errorf("unknown SponsorBlock category %q", category)
+		}
+	}
+
+	return nil
+}
+
+// Key identifies the processing on the downloads record, so that a file is
+// only reused by records asking for the same processing. It is empty when
+// there is none.
+func (p Processing) Key() string {
+	parts := []string{}
+	if p.LoudnessTarget != 0 {
+		parts = append(parts, "lufs"+strconv.FormatFloat(p.LoudnessTarget, 'f', -1, 64))
+	}
+	if p.TrimSilence {
+		parts = append(parts, "trim")
+	}
+	if p.Speed != 0 && p.Speed != 1 {
+		parts = append(parts, "x"+strconv.FormatFloat(p.Speed, 'f', -1, 64))
+	}
+	if len(p.SkipCategories) > 0 {
+		categories := slices.Clone(p.SkipCategories)
+		slices.Sort(categories)
+		parts = append(parts, "skip:"+strings.Join(categories, "+"))
+	}
+	return strings.Join(parts, ",")
+}
+
+// ParseProcessing reads a key written by Key.
+func ParseProcessing(key string) Processing {
+	p := Processing{}
+	for _, part := range strings.Split(key, ",") {
+		switch {
+		case strings.HasPrefix(part, "skip:"):
+			p.SkipCategories = strings.Split(strings.TrimPrefix(part, "skip:"), "+")
+		case part == "trim":
+			p.TrimSilence = true
+		case strings.HasPrefix(part, "lufs"):
+			p.LoudnessTarget, _ = strconv.ParseFloat(strings.TrimPrefix(part, "lufs"), 64)
+		case strings.HasPrefix(part, "x"):
+			p.Speed, _ = strconv.ParseFloat(strings.TrimPrefix(part, "x"), 64)
+		}
+	}
+	return p
+}
+
+// filters builds the ffmpeg audio filter chain. Segments are cut and silence
+// is trimmed first so they don't count towards the measured loudness, and
+// normalization runs last on the audio that ends up in the file.
+func (p Processing) filters() string {
+	filters := []string{}
+	if len(p.Cuts) > 0 {
+		between := []string{}
+		for _, cut := range p.Cuts {
+			between = append(between, fmt.Sprintf("between(t,%.3f,%.3f)", cut.Start, cut.End))
+		}
+		filters = append(filters, "aselect='not("+strings.Join(between, "+")+")'", "asetpts=N/SR/TB")
+	}
+	if p.TrimSilence {
+		// trailing silence is trimmed by reversing, trimming the start and
+		// reversing back
+		trim := "silenceremove=start_periods=1:start_duration=0.5:start_threshold=-50dB"
+		filters = append(filters, trim, "areverse", trim, "areverse")
+	}
+	if p.Speed != 0 && p.Speed != 1 {
+		filters = append(filters, "atempo="+strconv.FormatFloat(p.Speed, 'f', -1, 64))
+	}
+	if p.LoudnessTarget != 0 {
+		filters = append(filters, "loudnorm=I="+strconv.FormatFloat(p.LoudnessTarget, 'f', -1, 64)+":TP=-1.5:LRA=11")
+	}
+	return strings.Join(filters, ",")
+}
@@ -0,0 +1,72 @@
+package audio_profiles
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Tags are the metadata written into a finished file, as ID3 frames for MP3,
+// iTunes atoms for MP4 and Vorbis comments for Ogg.
+type Tags struct {
+	Title   string
+	Artist  string
+	Album   string
+	Date    string
+	Comment string
+	// Cover is a JPEG image, attached as the front cover.
+	Cover []byte
+}
+
+// WriteTags tags the file at path in place. The audio and any chapters are
+// copied as they are. Ogg files get no cover, which ffmpeg can't write there.
+func WriteTags(path string, t Tags) error {
+	ext := strings.ToLower(filepath.Ext(path))
+	taggedPath := strings.TrimSuffix(path, filepath.Ext(path)) + "_tagged" + filepath.Ext(path)
+
+	args := []string{"-y", "-i", path}
+
+	withCover := len(t.Cover) > 0 && (ext == ".mp3" || ext == ".m4a")
+	if withCover {
+		coverPath := path + ".cover.jpg"
+		if err := os.WriteFile(coverPath, t.Cover, 0644); err != nil {
+			return err
+		}
+		defer os.Remove(coverPath)
+
+		args = append(args, "-i", coverPath, "-map", "0:a", "-map", "1:v", "-disposition:v", "attached_pic")
+	} else {
+		args = append(args, "-map", "0:a")
+	}
+	args = append(args, "-c", "copy")
+
+	metadata := [][2]string{
+		{"title", t.Title},
+		{"artist", t.Artist},
+		{"album", t.Album},
+		{"date", t.Date},
+		{"comment", t.Comment},
+	}
+	for _, m := range metadata {
+		if m[1] != "" {
+			args = append(args, "-metadata", m[0]+"="+m[1])
+		}
+	}
+
+	if ext == ".mp3" {
+		args = append(args, "-id3v2_version", "3")
+		if withCover {
+			args = append(args, "-metadata:s:v", "title=Album cover", "-metadata:s:v", "comment=Cover (front)")
+		}
+	}
+	args = append(args, taggedPath)
+
+	if out, err := exec.Command("ffmpeg", args...).CombinedOutput(); err != nil {
+		os.Remove(taggedPath)
+		return fmt.Errorf("failed to write tags: %w: %s", err, string(out))
+	}
+
+	return os.Rename(taggedPath, path)
+}
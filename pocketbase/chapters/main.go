@@ -0,0 +1,140 @@
+package chapters
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/lsherman98/yt-rss/pocketbase/sponsorblock"
+)
+
+// MimeType is the type of the Podcasting 2.0 chapters file.
+const MimeType = "application/json+chapters"
+
+type Chapter struct {
+	Title string  `json:"title"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// infoJSON is the part of yt-dlp's info JSON that holds the chapter markers.
+type infoJSON struct {
+	Chapters []struct {
+		Title     string  `json:"title"`
+		StartTime float64 `json:"start_time"`
+		EndTime   float64 `json:"end_time"`
+	} `json:"chapters"`
+}
+
+// FromInfoJSON reads the chapter markers of a video from yt-dlp's info JSON.
+// Videos without chapters return an empty list.
+func FromInfoJSON(raw []byte) ([]Chapter, error) {
+	if len(raw) == 0 {
+		return []Chapter{}, nil
+	}
+
+	info := infoJSON{}
+	if err := json.Unmarshal(raw, &info); err != nil {
+		return nil, fmt.Errorf("failed to decode chapters: %w", err)
+	}
+
+	list := []Chapter{}
+	for _, c := range info.Chapters {
+		if c.EndTime <= c.StartTime {
+			continue
+		}
+		list = append(list, Chapter{Title: c.Title, Start: c.StartTime, End: c.EndTime})
+	}
+	return list, nil
+}
+
+// Adjust moves chapters from the video's timeline onto the encoded file's,
+// which is shorter by the cut segments and sped up by speed. Chapters that
+// were cut entirely are dropped.
+func Adjust(list []Chapter, cuts []sponsorblock.Segment, speed float64) []Chapter {
+	if speed == 0 {
+		speed = 1
+	}
+
+	adjusted := []Chapter{}
+	for _, c := range list {
+		start := sponsorblock.Remove(cuts, c.Start) / speed
+		end := sponsorblock.Remove(cuts, c.End) / speed
+		if end-start < 1 {
+			continue
+		}
+		adjusted = append(adjusted, Chapter{Title: c.Title, Start: start, End: end})
+	}
+	return adjusted
+}
+
+// Embed writes the chapters into the audio file at path: ID3 CHAP/CTOC frames
+// for MP3, chapter atoms for MP4 and chapter comments for Ogg. The audio is
+// copied as is.
+func Embed(path string, list []Chapter) error {
+	if len(list) == 0 {
+		return nil
+	}
+
+	metadataPath := path + ".ffmetadata"
+	if err := os.WriteFile(metadataPath, []byte(ffmetadata(list)), 0644); err != nil {
+		return err
+	}
+	defer os.Remove(metadataPath)
+
+	ext := filepath.Ext(path)
+	taggedPath := strings.TrimSuffix(path, ext) + "_chapters" + ext
+
+	// ffmpeg-go maps every input stream to the output, which fails for the
+	// metadata input since it has none, so ffmpeg is called directly
+	args := []string{"-y", "-i", path, "-i", metadataPath, "-map", "0:a", "-map_metadata", "1", "-map_chapters", "1", "-c", "copy"}
+	if ext == ".mp3" {
+		args = append(args, "-id3v2_version", "3")
+	}
+	args = append(args, taggedPath)
+
+	if out, err := exec.Command("ffmpeg", args...).CombinedOutput(); err != nil {
+		os.Remove(taggedPath)
+		return fmt.Errorf("failed to embed chapters: %w: %s", err, string(out))
+	}
+
+	return os.Rename(taggedPath, path)
+}
+
+func ffmetadata(list []Chapter) string {
+	escape := strings.NewReplacer("\\", "\\\\", "=", "\\=", ";", "\\;", "#", "\\#", "\n", "\\\n")
+
+	var b strings.Builder
+	b.WriteString(";FFMETADATA1\n")
+	for _, c := range list {
+		b.WriteString("[CHAPTER]\nTIMEBASE=1/1000\n")
+		fmt.Fprintf(&b, "START=%d\nEND=%d\n", int64(c.Start*1000), int64(c.End*1000))
+		b.WriteString("title=" + escape.Replace(c.Title) + "\n")
+	}
+	return b.String()
+}
+
+// feedChapter is a chapter in the Podcasting 2.0 JSON chapters format.
+type feedChapter struct {
+	StartTime float64 `json:"startTime"`
+	EndTime   float64 `json:"endTime,omitempty"`
+	Title     string  `json:"title"`
+}
+
+type feedChapters struct {
+	Version  string        `json:"version"`
+	Chapters []feedChapter `json:"chapters"`
+}
+
+// FeedJSON encodes the chapters as a Podcasting 2.0 chapters file, linked
+// from episodes with the podcast:chapters tag.
+func FeedJSON(list []Chapter) ([]byte, error) {
+	file := feedChapters{Version: "1.2.0", Chapters: []feedChapter{}}
+	for _, c := range list {
+		file.Chapters = append(file.Chapters, feedChapter{StartTime: c.Start, EndTime: c.End, Title: c.Title})
+	}
+	return json.Marshal(file)
+}
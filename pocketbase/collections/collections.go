@@ -16,4 +16,6 @@ const (
 	StripeCustomers     = "stripe_customers"
 	StripeSubscriptions = "stripe_subscriptions"
 	Queue               = "queue"
+	Proxies             = "proxies"
+	DownloadLinks       = "download_links"
 )
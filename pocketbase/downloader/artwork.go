@@ -0,0 +1,40 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/lsherman98/yt-rss/pocketbase/artwork"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+	"github.com/wader/goutubedl"
+)
+
+var artworkClient = &http.Client{Timeout: 30 * time.Second}
+
+// attachArtwork stores the video's thumbnail on the download as square
+// episode artwork. Episodes without it fall back to the podcast's image.
+func attachArtwork(ctx context.Context, app core.App, result *goutubedl.Result, download *core.Record) {
+	img, err := artwork.Fetch(ctx, artworkClient, artwork.Candidates(result.Info))
+	if err != nil {
+		app.Logger().Warn("Downloader: failed to fetch artwork", "video_id", result.Info.ID, "error", err)
+		return
+	}
+
+	content, err := artwork.Square(img)
+	if err != nil {
+		app.Logger().Warn("Downloader: failed to resize artwork", "video_id", result.Info.ID, "error", err)
+		return
+	}
+
+	file, err := filesystem.NewFileFromBytes(content, result.Info.ID+".jpg")
+	if err != nil {
+		return
+	}
+
+	download.Set("artwork", file)
+	if err := app.Save(download); err != nil {
+		app.Logger().Warn("Downloader: failed to save artwork", "video_id", result.Info.ID, "error", err)
+	}
+}
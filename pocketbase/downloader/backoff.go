@@ -0,0 +1,63 @@
+package downloader
+
+import (
+	"math"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"time"
+)
+
+type backoffPolicy struct {
+	base       time.Duration
+	multiplier float64
+	jitter     float64
+	max        time.Duration
+}
+
+func loadBackoffPolicy() backoffPolicy {
+	policy := backoffPolicy{
+		base:       30 * time.Second,
+		multiplier: 2,
+		jitter:     0.2,
+		max:        30 * time.Minute,
+	}
+
+	if d, err := time.ParseDuration(os.Getenv("DOWNLOAD_BACKOFF_BASE")); err == nil && d > 0 {
+		policy.base = d
+	}
+	if m, err := strconv.ParseFloat(os.Getenv("DOWNLOAD_BACKOFF_MULTIPLIER"), 64); err == nil && m >= 1 {
+		policy.multiplier = m
+	}
+	if j, err := strconv.ParseFloat(os.Getenv("DOWNLOAD_BACKOFF_JITTER"), 64); err == nil && j >= 0 && j <= 1 {
+		policy.jitter = j
+	}
+	if d, err := time.ParseDuration(os.Getenv("DOWNLOAD_BACKOFF_MAX")); err == nil && d > 0 {
+		policy.max = d
+	}
+
+	return policy
+}
+
+// delay returns how long to wait before the given attempt (1 is the first
+// retry): base * multiplier^(attempt-1), capped at max, with +/- jitter.
+func (p backoffPolicy) delay(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	d := float64(p.base) * math.Pow(p.multiplier, float64(attempt-1))
+	if d > float64(p.max) {
+		d = float64(p.max)
+	}
+
+	if p.jitter > 0 {
+		d += d * p.jitter * (rand.Float64()*2 - 1)
+	}
+
+	return time.Duration(d)
+}
+
+func (p backoffPolicy) nextAttemptAt(attempt int) time.Time {
+	return time.Now().Add(p.delay(attempt))
+}
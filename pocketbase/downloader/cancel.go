@@ -0,0 +1,105 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/lsherman98/yt-rss/pocketbase/collections"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+var ErrNotCancellable = errors.New("record has already finished and cannot be cancelled")
+
+// running holds the cancel functions of the queue records this process is
+// currently working on, keyed by queue id.
+var running = struct {
+	sync.Mutex
+	cancels map[string]context.CancelFunc
+}{cancels: map[string]context.CancelFunc{}}
+
+func startRun(queueId string) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	running.Lock()
+	running.cancels[queueId] = cancel
+	running.Unlock()
+
+	return ctx, func() {
+		running.Lock()
+		delete(running.cancels, queueId)
+		running.Unlock()
+		cancel()
+	}
+}
+
+func activeRuns() int64 {
+	running.Lock()
+	defer running.Unlock()
+	return int64(len(running.cancels))
+}
+
+func stopRun(queueId string) {
+	running.Lock()
+	cancel, ok := running.cancels[queueId]
+	running.Unlock()
+
+	if ok {
+		cancel()
+	}
+}
+
+// Cancel stops a jobs or items record. The queue record is marked CANCELLED so
+// that it is never picked up again and late Oxylabs callbacks are ignored, an
+// in-flight download is aborted, and the record itself ends as CANCELLED.
+//
+// Only runs in this process are stopped right away. A worker in another
+// process notices on its next heartbeat, and every status it writes is
+// conditional on still owning a PROCESSING record, so it can't undo the
+// cancel in the meantime.
+func Cancel(app core.App, record *core.Record) error {
+	switch record.GetString("status") {
+	case "SUCCESS", "ERROR", "CANCELLED":
+		return ErrNotCancellable
+	}
+
+	queues, err := app.FindRecordsByFilter(
+		collections.Queue,
+		"record_id = {:record} && collection = {:collection}",
+		"-created",
+		1,
+		0,
+		dbx.Params{"record": record.Id, "collection": record.Collection().Name},
+	)
+	if err != nil {
+		return err
+	}
+
+	if len(queues) > 0 {
+		queue := queues[0]
+
+		// a worker finishing the record at the same time either completes
+		// first or finds the record cancelled
+		res, err := app.DB().NewQuery(`
+			UPDATE queue
+			SET status = 'CANCELLED', worker_id = '', lease_expires_at = '', updated = {:now}
+			WHERE id = {:id} AND status NOT IN ('COMPLETED', 'FAILED')
+		`).Bind(dbx.Params{
+			"id":  queue.Id,
+			"now": types.NowDateTime().String(),
+		}).Execute()
+		if err != nil {
+			return err
+		}
+		if cancelled, _ := res.RowsAffected(); cancelled != 1 {
+			return ErrNotCancellable
+		}
+
+		stopRun(queue.Id)
+	}
+
+	record.Set("status", "CANCELLED")
+	return app.Save(record)
+}
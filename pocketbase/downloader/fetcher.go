@@ -0,0 +1,191 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/lsherman98/yt-rss/pocketbase/audio_profiles"
+	"github.com/lsherman98/yt-rss/pocketbase/fetch_errors"
+	"github.com/lsherman98/yt-rss/pocketbase/oxylabs"
+	"github.com/lsherman98/yt-rss/pocketbase/proxy_pool"
+	"github.com/lsherman98/yt-rss/pocketbase/sponsorblock"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+	"github.com/wader/goutubedl"
+)
+
+const (
+	FetcherYtdlp   = "ytdlp"
+	FetcherOxylabs = "oxylabs"
+	FetcherLocal   = "local"
+	// FetcherOriginal encodes from an original kept by an earlier download. It
+	// is always part of the chain and can't be configured.
+	FetcherOriginal = "original"
+)
+
+var defaultFetchers = []string{FetcherOxylabs, FetcherYtdlp}
+
+var errInfoNotSupported = errors.New("fetcher does not support info lookups")
+
+// Fetcher is a backend that can look up a video and produce its audio file.
+// Async fetchers hand the work to an external provider and return a pending
+// result; the file is delivered later through a callback.
+type Fetcher interface {
+	Name() string
+	Async() bool
+	GetInfo(ctx context.Context, url string) (*goutubedl.Result, error)
+	Fetch(ctx context.Context, req FetchRequest) (*FetchResult, error)
+}
+
+type FetchRequest struct {
+	URL        string
+	Result     *goutubedl.Result
+	QueueID    string
+	RetryCount int
+	Profile    audio_profiles.Profile
+	Processing audio_profiles.Processing
+}
+
+type FetchResult struct {
+	Fetcher string
+	File    *filesystem.File
+	Path    string
+	Pending bool
+	JobID   string
+	// CallbackSecret authenticates the callback for a pending job.
+	CallbackSecret string
+	// OriginalPath is the uncut source, kept when segments were cut.
+	OriginalPath string
+	Cuts         []sponsorblock.Segment
+}
+
+// FetcherChain tries each fetcher in order until one succeeds.
+type FetcherChain []Fetcher
+
+// GetInfo stops at the first permanent error, since another backend will not
+// make a private or deleted video available.
+func (c FetcherChain) GetInfo(ctx context.Context, url string) (*goutubedl.Result, error) {
+	var lastErr error
+	for _, f := range c {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		result, err := f.GetInfo(ctx, url)
+		if errors.Is(err, errInfoNotSupported) {
+			continue
+		}
+		if err != nil {
+			lastErr = fmt.Errorf("%s: %w", f.Name(), err)
+			if fetch_errors.KindOf(err) == fetch_errors.Permanent {
+				return nil, lastErr
+			}
+			continue
+		}
+		return result, nil
+	}
+
+	if lastErr == nil {
+		lastErr = errors.New("no fetcher in the chain supports info lookups")
+	}
+	return nil, lastErr
+}
+
+// Fetch runs the chain. Async fetchers only get the first attempt of a queue
+// record so that retries go through the synchronous fetchers. A permanent
+// error stops the chain, like in GetInfo.
+func (c FetcherChain) Fetch(ctx context.Context, req FetchRequest) (*FetchResult, error) {
+	var lastErr error
+	for _, f := range c {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if f.Async() && req.RetryCount > 0 {
+			continue
+		}
+
+		res, err := f.Fetch(ctx, req)
+		if err != nil {
+			lastErr = fmt.Errorf("%s: %w", f.Name(), err)
+			if fetch_errors.KindOf(err) == fetch_errors.Permanent {
+				return nil, lastErr
+			}
+			continue
+		}
+		res.Fetcher = f.Name()
+		return res, nil
+	}
+
+	if lastErr == nil {
+		lastErr = errors.New("no fetcher available for this attempt")
+	}
+	return nil, lastErr
+}
+
+func parseFetcherNames(value string) ([]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return defaultFetchers, nil
+	}
+
+	names := []string{}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "":
+			continue
+		case FetcherYtdlp, FetcherOxylabs, FetcherLocal:
+			names = append(names, name)
+		default:
+			return nil, fmt.Errorf("unknown fetcher %q in DOWNLOAD_FETCHERS", name)
+		}
+	}
+
+	if len(names) == 0 {
+		return nil, errors.New("DOWNLOAD_FETCHERS does not contain any fetcher")
+	}
+	return names, nil
+}
+
+// attempt is the fetcher chain for one try at a queue record and the proxy
+// checked out for it.
+type attempt struct {
+	fetchers FetcherChain
+	proxy    *proxy_pool.Lease
+	// ytdlp is the chain's yt-dlp fetcher, the only one using the proxy.
+	ytdlp *ytdlpFetcher
+}
+
+// releaseProxy gives the attempt's proxy back. Only calls that went through
+// the proxy count towards its health, so a file fetched by Oxylabs or from a
+// kept original leaves it as it was.
+func (a *attempt) releaseProxy(ctx context.Context) {
+	if ctx.Err() != nil || a.ytdlp == nil || !a.ytdlp.proxyUsed {
+		a.proxy.Return()
+		return
+	}
+	a.proxy.Release(a.ytdlp.proxyErr)
+}
+
+// newFetcherChain builds the configured chain. It always starts with the
+// originals kept from earlier downloads, which need no network access.
+func newFetcherChain(app core.App, names []string, oxylabClient *oxylabs.Client, ytdlp *ytdlpFetcher) FetcherChain {
+	chain := FetcherChain{&originalFetcher{app: app}}
+	for _, name := range names {
+		switch name {
+		case FetcherYtdlp:
+			chain = append(chain, ytdlp)
+		case FetcherOxylabs:
+			if oxylabClient == nil {
+				app.Logger().Warn("Downloader: oxylabs fetcher configured without a client, skipping")
+				continue
+			}
+			chain = append(chain, &oxylabsFetcher{app: app, client: oxylabClient})
+		case FetcherLocal:
+			chain = append(chain, &localFetcher{dir: os.Getenv("DOWNLOAD_LOCAL_DIR")})
+		}
+	}
+	return chain
+}
@@ -0,0 +1,261 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/lsherman98/yt-rss/pocketbase/audio_profiles"
+	"github.com/lsherman98/yt-rss/pocketbase/collections"
+	"github.com/lsherman98/yt-rss/pocketbase/oxylabs"
+	"github.com/lsherman98/yt-rss/pocketbase/ytdlp"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+	"github.com/pocketbase/pocketbase/tools/security"
+	"github.com/wader/goutubedl"
+)
+
+type ytdlpFetcher struct {
+	client *ytdlp.Client
+	// proxyErr is the outcome of the last call that says something about the
+	// client's proxy, a failed lookup or the fetch. proxyUsed is false until
+	// there was one.
+	proxyUsed bool
+	proxyErr  error
+}
+
+func (f *ytdlpFetcher) Name() string {
+	return FetcherYtdlp
+}
+
+func (f *ytdlpFetcher) Async() bool {
+	return false
+}
+
+func (f *ytdlpFetcher) GetInfo(ctx context.Context, url string) (*goutubedl.Result, error) {
+	result, err := f.client.GetInfo(ctx, url)
+	if err != nil {
+		f.proxyUsed, f.proxyErr = true, err
+	}
+	return result, err
+}
+
+func (f *ytdlpFetcher) Fetch(ctx context.Context, req FetchRequest) (*FetchResult, error) {
+	output, err := f.client.Download(ctx, req.URL, req.Result, req.Profile, req.Processing)
+	f.proxyUsed, f.proxyErr = true, err
+	if err != nil {
+		return nil, err
+	}
+
+	return &FetchResult{File: output.File, Path: output.Path, OriginalPath: output.OriginalPath}, nil
+}
+
+type oxylabsFetcher struct {
+	app    core.App
+	client *oxylabs.Client
+}
+
+func (f *oxylabsFetcher) Name() string {
+	return FetcherOxylabs
+}
+
+func (f *oxylabsFetcher) Async() bool {
+	return true
+}
+
+func (f *oxylabsFetcher) GetInfo(ctx context.Context, url string) (*goutubedl.Result, error) {
+	return nil, errInfoNotSupported
+}
+
+func (f *oxylabsFetcher) Fetch(ctx context.Context, req FetchRequest) (*FetchResult, error) {
+	secret := security.RandomString(32)
+	resp, err := f.client.Start(ctx, req.Result.Info.ID, req.QueueID, secret)
+	if err != nil {
+		// the error carries the response body, which explains a rejected job
+		f.app.Logger().Warn("Downloader: Oxylabs did not accept the job", "job_id", req.QueueID, "video_id", req.Result.Info.ID, "error", err)
+		return nil, err
+	}
+
+	return &FetchResult{Pending: true, JobID: resp.ID, CallbackSecret: secret}, nil
+}
+
+// originalFetcher encodes from the uncut source kept by an earlier download
+// of the same video, so a new profile doesn't download the video again.
+type originalFetcher struct {
+	app core.App
+}
+
+var errNoOriginal = errors.New("no original kept for this video")
+
+func (f *originalFetcher) Name() string {
+	return FetcherOriginal
+}
+
+func (f *originalFetcher) Async() bool {
+	return false
+}
+
+func (f *originalFetcher) GetInfo(ctx context.Context, url string) (*goutubedl.Result, error) {
+	return nil, errInfoNotSupported
+}
+
+func (f *originalFetcher) Fetch(ctx context.Context, req FetchRequest) (*FetchResult, error) {
+	source, err := f.app.FindFirstRecordByFilter(
+		collections.Downloads,
+		"video_id = {:videoId} && original != ''",
+		dbx.Params{"videoId": req.Result.Info.ID},
+	)
+	if err != nil {
+		return nil, errNoOriginal
+	}
+
+	fsys, err := f.app.NewFilesystem()
+	if err != nil {
+		return nil, err
+	}
+	defer fsys.Close()
+
+	r, err := fsys.GetReader(source.BaseFilesPath() + "/" + source.GetString("original"))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	directory := filepath.Join("pb_data", "output")
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return nil, err
+	}
+
+	name := req.Result.Info.ID + "_" + req.Profile.Name + "_" + security.PseudorandomString(6)
+	srcPath := filepath.Join(directory, name+"_original"+filepath.Ext(source.GetString("original")))
+	dst, err := os.Create(srcPath)
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.Copy(dst, r)
+	dst.Close()
+	defer os.Remove(srcPath)
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(directory, name+"."+req.Profile.Extension)
+	if err := audio_profiles.Transcode(srcPath, path, req.Profile, req.Processing); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	if ctx.Err() != nil {
+		os.Remove(path)
+		return nil, ctx.Err()
+	}
+
+	file, err := filesystem.NewFileFromPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FetchResult{File: file, Path: path}, nil
+}
+
+// localFetcher serves videos from a directory of yt-dlp style fixtures:
+// <video_id>.info.json next to <video_id>.mp3. Used for development and tests.
+type localFetcher struct {
+	dir string
+}
+
+var videoIdRegex = regexp.MustCompile(`(?:v=|youtu\.be/)([\w-]{11})`)
+
+func (f *localFetcher) Name() string {
+	return FetcherLocal
+}
+
+func (f *localFetcher) Async() bool {
+	return false
+}
+
+func (f *localFetcher) directory() string {
+	if f.dir == "" {
+		return filepath.Join("pb_data", "local")
+	}
+	return f.dir
+}
+
+func (f *localFetcher) GetInfo(ctx context.Context, url string) (*goutubedl.Result, error) {
+	match := videoIdRegex.FindStringSubmatch(url)
+	if match == nil {
+		return nil, fmt.Errorf("could not extract video id from %q", url)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(f.directory(), match[1]+".info.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	result := goutubedl.Result{RawJSON: raw}
+	if err := json.Unmarshal(raw, &result.Info); err != nil {
+		return nil, fmt.Errorf("failed to parse info file: %w", err)
+	}
+	if result.Info.ID == "" {
+		result.Info.ID = match[1]
+	}
+
+	return &result, nil
+}
+
+func (f *localFetcher) Fetch(ctx context.Context, req FetchRequest) (*FetchResult, error) {
+	srcPath := filepath.Join(f.directory(), req.Result.Info.ID+".mp3")
+
+	directory := filepath.Join("pb_data", "output")
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(directory, req.Result.Info.ID+"_"+req.Profile.Name+"."+req.Profile.Extension)
+
+	// fixtures are MP3, anything else goes through the same encode as yt-dlp
+	if req.Profile.MimeType != "audio/mpeg" || req.Profile.Channels != 0 || !req.Processing.IsZero() {
+		if err := audio_profiles.Transcode(srcPath, path, req.Profile, req.Processing); err != nil {
+			os.Remove(path)
+			return nil, err
+		}
+	} else if err := copyFile(srcPath, path); err != nil {
+		return nil, err
+	}
+	if ctx.Err() != nil {
+		os.Remove(path)
+		return nil, ctx.Err()
+	}
+
+	file, err := filesystem.NewFileFromPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FetchResult{File: file, Path: path}, nil
+}
+
+func copyFile(srcPath, path string) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, src); err != nil {
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package downloader
+
+import (
+	"os"
+
+	"github.com/lsherman98/yt-rss/pocketbase/audio_profiles"
+	"github.com/lsherman98/yt-rss/pocketbase/chapters"
+	"github.com/lsherman98/yt-rss/pocketbase/collections"
+	"github.com/lsherman98/yt-rss/pocketbase/rss_utils"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// finalizeDownload completes a queue record once its file is known. Every way
+// a record can finish ends here, whether the file was fetched by yt-dlp, came
+// back from Oxylabs or was already downloaded for another record, so they all
+// leave the same state behind.
+//
+// fetched is the file this record fetched, or nil when the download already
+// has one. Chapters and tags are written into it before it is stored, and its
+// temporary file is removed afterwards. Downloads stored before files were
+// tagged are tagged when reused. Items also get their own copy of the file,
+// with their podcast as the album. Usage is charged by the stored file's size.
+func finalizeDownload(app core.App, queue, record, download *core.Record, fetched *FetchResult) error {
+	if fetched != nil {
+		defer removeTempFile(app, fetched.Path)
+		defer removeTempFile(app, fetched.OriginalPath)
+
+		embedChapters(app, download, fetched)
+		if err := audio_profiles.WriteTags(fetched.Path, tagsFor(app, download)); err != nil {
+			app.Logger().Warn("Downloader: failed to tag file", "download_id", download.Id, "error", err)
+		} else {
+			download.Set("tagged", true)
+		}
+
+		// chapters and tags change the file's size
+		file, err := filesystem.NewFileFromPath(fetched.Path)
+		if err != nil {
+			return err
+		}
+		file.Name = fetched.File.Name
+		fetched.File = file
+
+		download.Set("file", fetched.File)
+		download.Set("size", fetched.File.Size)
+
+		if fetched.OriginalPath != "" {
+			original, err := filesystem.NewFileFromPath(fetched.OriginalPath)
+			if err != nil {
+				return err
+			}
+			download.Set("original", original)
+		}
+
+		if len(fetched.Cuts) > 0 {
+			processing := audio_profiles.ParseProcessing(download.GetString("processing"))
+			processing.Cuts = fetched.Cuts
+			speed := processing.Speed
+			if speed == 0 {
+				speed = 1
+			}
+
+			download.Set("segments", fetched.Cuts)
+			download.Set("duration", max(download.GetFloat("duration")-processing.CutLength()/speed, 0))
+		}
+
+		if err := app.Save(download); err != nil {
+			return err
+		}
+	} else if !download.GetBool("tagged") {
+		if err := tagStoredFile(app, record, download); err != nil {
+			app.Logger().Warn("Downloader: failed to tag stored file", "download_id", download.Id, "error", err)
+		}
+	}
+
+	isItem := record.Collection().Name == collections.Items
+
+	// an item without its own copy is served the download's file
+	var itemFile *filesystem.File
+	if isItem {
+		path, err := tagItemFile(app, record, download, fetched)
+		if err == nil {
+			defer removeTempFile(app, path)
+			itemFile, err = filesystem.NewFileFromPath(path)
+		}
+		if err != nil {
+			app.Logger().Warn("Downloader: failed to tag item file", "item_id", record.Id, "error", err)
+		} else {
+			itemFile.Name = download.GetString("file")
+		}
+	}
+
+	// the record is loaded again since it can be edited or scheduled while it
+	// downloads, and a record cancelled in the meantime stays cancelled
+	err := app.RunInTransaction(func(txApp core.App) error {
+		if err := updateQueue(txApp, queue, dbx.Params{"status": "COMPLETED"}); err != nil {
+			return err
+		}
+
+		current, err := txApp.FindRecordById(record.Collection().Id, record.Id)
+		if err != nil {
+			return err
+		}
+
+		current.Set("download", download.Id)
+		current.Set("status", "SUCCESS")
+		if itemFile != nil {
+			current.Set("file", itemFile)
+			current.Set("size", itemFile.Size)
+		}
+		if isItem && current.GetString("publication") == rss_utils.Published && current.GetDateTime("published_at").IsZero() {
+			current.Set("published_at", publishDate(txApp, current, download))
+		}
+		if err := txApp.Save(current); err != nil {
+			return err
+		}
+
+		record = current
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// the feed is rendered from the finished items, so it's rebuilt once the
+	// item is saved. A feed that fails to rebuild is only stale until the
+	// next change.
+	if isItem {
+		if err := addToFeed(app, record); err != nil {
+			app.Logger().Error("Downloader: failed to rebuild feed", "item_id", record.Id, "error", err)
+		}
+	}
+
+	meterUsage(app, record.GetString("user"), download.GetInt("size"))
+
+	return nil
+}
+
+// embedChapters moves the video's chapters onto the fetched file's timeline
+// and writes them into it. A file whose chapters can't be written is still
+// stored, only without chapter navigation.
+func embedChapters(app core.App, download *core.Record, fetched *FetchResult) {
+	videoChapters := []chapters.Chapter{}
+	if err := download.UnmarshalJSONField("chapters", &videoChapters); err != nil || len(videoChapters) == 0 {
+		return
+	}
+
+	processing := audio_profiles.ParseProcessing(download.GetString("processing"))
+	fileChapters := chapters.Adjust(videoChapters, fetched.Cuts, processing.Speed)
+	download.Set("chapters", fileChapters)
+
+	if err := chapters.Embed(fetched.Path, fileChapters); err != nil {
+		app.Logger().Warn("Downloader: failed to embed chapters", "download_id", download.Id, "error", err)
+	}
+}
+
+// publishDate is when a finished item is published: now, or when the video
+// was uploaded to YouTube for podcasts set to use upload dates.
+func publishDate(app core.App, item, download *core.Record) types.DateTime {
+	uploaded := download.GetDateTime("upload_date")
+	if uploaded.IsZero() {
+		return types.NowDateTime()
+	}
+
+	podcastRecord, err := app.FindRecordById(collections.Podcasts, item.GetString("podcast"))
+	if err != nil || !podcastRecord.GetBool("use_upload_date") {
+		return types.NowDateTime()
+	}
+	return uploaded
+}
+
+// addToFeed rebuilds the feed of the item's podcast, which now lists the
+// item's download as an episode.
+func addToFeed(app core.App, item *core.Record) error {
+	podcastRecord, err := app.FindRecordById(collections.Podcasts, item.GetString("podcast"))
+	if err != nil {
+		return err
+	}
+
+	return rss_utils.RebuildFeed(app, podcastRecord)
+}
+
+func meterUsage(app core.App, user string, fileSize int) {
+	monthlyUsage, err := getMonthlyUsage(app, user)
+	if err != nil {
+		app.Logger().Error("Downloader: failed to get monthly usage", "user", user, "error", err)
+		return
+	}
+
+	updateMonthlyUsage(app, monthlyUsage, monthlyUsage.GetInt("usage"), fileSize)
+}
+
+func removeTempFile(app core.App, path string) {
+	if path == "" {
+		return
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		app.Logger().Error("Downloader: failed to delete temporary file", "path", path, "error", err)
+	}
+}
@@ -0,0 +1,158 @@
+package downloader
+
+import (
+	"github.com/lsherman98/yt-rss/pocketbase/collections"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/wader/goutubedl"
+)
+
+// A flight is the single fetch of a video shared by every queue record that
+// asks for it. The downloads record is the flight: the unique index on
+// (video_id, profile, processing) lets only one worker create it, and fetching_queue
+// names the queue record doing the fetch. Everyone else waits in WAITING
+// until the file is set.
+type flightRole int
+
+const (
+	flightLeader flightRole = iota
+	flightFollower
+	flightDone
+)
+
+func findDownload(app core.App, videoId, profile, processing string) (*core.Record, error) {
+	return app.FindFirstRecordByFilter(
+		collections.Downloads,
+		"video_id = {:videoId} && profile = {:profile} && processing = {:processing}",
+		dbx.Params{"videoId": videoId, "profile": profile, "processing": processing},
+	)
+}
+
+// joinFlight returns the downloads record for the video and this queue
+// record's part in fetching it. A flight whose leader is no longer running is
+// taken over.
+func joinFlight(app core.App, result *goutubedl.Result, queue *core.Record, out output) (*core.Record, flightRole, error) {
+	download, role, err := findFlight(app, result, queue, out)
+	if err != nil || role != flightLeader {
+		return download, role, err
+	}
+
+	// the Oxylabs callback finds the download through the queue record
+	if err := updateQueue(app, queue, dbx.Params{"download": download.Id}); err != nil {
+		return nil, flightLeader, err
+	}
+
+	return download, flightLeader, nil
+}
+
+func findFlight(app core.App, result *goutubedl.Result, queue *core.Record, out output) (*core.Record, flightRole, error) {
+	profile, processing := out.profile.Name, out.processing.Key()
+
+	download, err := findDownload(app, result.Info.ID, profile, processing)
+	if err != nil {
+		download, err = createDownloadRecord(app, result, queue, out)
+		if err == nil {
+			return download, flightLeader, nil
+		}
+
+		// another worker created it first
+		download, err = findDownload(app, result.Info.ID, profile, processing)
+		if err != nil {
+			return nil, flightLeader, err
+		}
+	}
+
+	if download.GetString("file") != "" {
+		return download, flightDone, nil
+	}
+
+	leader := download.GetString("fetching_queue")
+	if leader == queue.Id {
+		return download, flightLeader, nil
+	}
+
+	if !flightActive(app, leader) {
+		res, err := app.DB().Update(
+			collections.Downloads,
+			dbx.Params{"fetching_queue": queue.Id},
+			dbx.HashExp{"id": download.Id, "fetching_queue": leader},
+		).Execute()
+		if err != nil {
+			return nil, flightLeader, err
+		}
+		if taken, _ := res.RowsAffected(); taken == 1 {
+			download.Set("fetching_queue", queue.Id)
+			app.Logger().Info("Downloader: took over stalled fetch", "job_id", queue.Id, "video_id", result.Info.ID, "previous", leader)
+			return download, flightLeader, nil
+		}
+	}
+
+	return download, flightFollower, nil
+}
+
+// flightActive reports whether the leading queue record will still produce
+// the file. Records retrying after a backoff are still active.
+func flightActive(app core.App, leader string) bool {
+	if leader == "" {
+		return false
+	}
+
+	queue, err := app.FindRecordById(collections.Queue, leader)
+	if err != nil {
+		return false
+	}
+
+	status := queue.GetString("status")
+	return status == "PENDING" || status == "PROCESSING"
+}
+
+// waitForFlight parks the queue record until the leader is done. The worker
+// is released; reapFinishedFlights puts the record back in the queue.
+func waitForFlight(app core.App, queue, download *core.Record) error {
+	app.Logger().Info("Downloader: waiting on in-flight fetch", "job_id", queue.Id, "download_id", download.Id)
+
+	return updateQueue(app, queue, dbx.Params{
+		"status":           "WAITING",
+		"download":         download.Id,
+		"worker_id":        "",
+		"lease_expires_at": "",
+	})
+}
+
+// finishedDownload returns the download a waiting queue record was parked on,
+// if its file has been set in the meantime.
+func finishedDownload(app core.App, queue *core.Record) *core.Record {
+	downloadId := queue.GetString("download")
+	if downloadId == "" {
+		return nil
+	}
+
+	download, err := app.FindRecordById(collections.Downloads, downloadId)
+	if err != nil || download.GetString("file") == "" {
+		return nil
+	}
+	return download
+}
+
+// reapFinishedFlights returns WAITING records to PENDING once their flight is
+// over, either because the file is ready or because the leader stopped. They
+// then either complete from the file or take the flight over.
+func reapFinishedFlights(app core.App) {
+	res, err := app.DB().NewQuery(`
+		UPDATE queue
+		SET status = 'PENDING'
+		WHERE status = 'WAITING' AND download NOT IN (
+			SELECT d.id FROM downloads d
+			JOIN queue q ON q.id = d.fetching_queue
+			WHERE d.file = '' AND q.status IN ('PENDING', 'PROCESSING')
+		)
+	`).Execute()
+	if err != nil {
+		app.Logger().Error("Downloader: failed to release waiting jobs", "error", err)
+		return
+	}
+
+	if released, _ := res.RowsAffected(); released > 0 {
+		app.Logger().Info("Downloader: released jobs waiting on a fetch", "count", released)
+	}
+}
@@ -0,0 +1,207 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/lsherman98/yt-rss/pocketbase/collections"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// errLostQueue is returned when a worker updates a queue record it no longer
+// owns because the record was cancelled or reaped in the meantime.
+var errLostQueue = errors.New("queue record is no longer owned by this worker")
+
+// claimQueue moves a PENDING queue record to PROCESSING for the given worker.
+// The conditional update makes sure only one worker wins when several
+// instances share the database.
+func claimQueue(app core.App, queueId, workerId string, lease time.Duration) (bool, error) {
+	now := types.NowDateTime()
+	expires, _ := types.ParseDateTime(time.Now().Add(lease))
+
+	res, err := app.DB().NewQuery(`
+		UPDATE queue
+		SET status = 'PROCESSING', worker_id = {:worker}, lease_expires_at = {:expires}, updated = {:now}
+		WHERE id = {:id} AND status = 'PENDING'
+	`).Bind(dbx.Params{
+		"id":      queueId,
+		"worker":  workerId,
+		"expires": expires.String(),
+		"now":     now.String(),
+	}).Execute()
+	if err != nil {
+		return false, err
+	}
+
+	claimed, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return claimed == 1, nil
+}
+
+// renewLease extends the lease while the worker still owns the record. It
+// returns false once the record was cancelled or reaped.
+func renewLease(app core.App, queueId, workerId string, lease time.Duration) (bool, error) {
+	expires, _ := types.ParseDateTime(time.Now().Add(lease))
+
+	res, err := app.DB().NewQuery(`
+		UPDATE queue
+		SET lease_expires_at = {:expires}
+		WHERE id = {:id} AND worker_id = {:worker} AND status = 'PROCESSING'
+	`).Bind(dbx.Params{
+		"id":      queueId,
+		"worker":  workerId,
+		"expires": expires.String(),
+	}).Execute()
+	if err != nil {
+		return false, err
+	}
+
+	renewed, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return renewed == 1, nil
+}
+
+// updateQueue sets fields on a queue record the worker is processing. Only the
+// given fields are written, so the lease renewed by the heartbeat in the
+// meantime is kept. It returns errLostQueue once the worker no longer owns
+// the record.
+func updateQueue(app core.App, queue *core.Record, fields dbx.Params) error {
+	workerId := queue.GetString("worker_id")
+	if workerId == "" {
+		return errLostQueue
+	}
+
+	fields["updated"] = types.NowDateTime().String()
+	res, err := app.DB().Update(
+		collections.Queue,
+		fields,
+		dbx.HashExp{"id": queue.Id, "worker_id": workerId, "status": "PROCESSING"},
+	).Execute()
+	if err != nil {
+		return err
+	}
+
+	if updated, _ := res.RowsAffected(); updated != 1 {
+		return errLostQueue
+	}
+
+	for field, value := range fields {
+		queue.Set(field, value)
+	}
+	return nil
+}
+
+// updateRecord sets fields on the jobs or items record of a queue record the
+// worker owns. Ownership is checked in the same transaction, so a record that
+// was cancelled in the meantime is never written over.
+func updateRecord(app core.App, queue, record *core.Record, fields map[string]any) error {
+	return app.RunInTransaction(func(txApp core.App) error {
+		if err := updateQueue(txApp, queue, dbx.Params{}); err != nil {
+			return err
+		}
+		return saveRecordFields(txApp, record, fields)
+	})
+}
+
+// saveRecordFields sets fields on a fresh copy of record and brings record up
+// to date with it. Saving the copy loaded when the job started would undo the
+// edits made while it downloads, like a custom title or a schedule.
+func saveRecordFields(app core.App, record *core.Record, fields map[string]any) error {
+	current, err := app.FindRecordById(record.Collection().Id, record.Id)
+	if err != nil {
+		return err
+	}
+
+	for field, value := range fields {
+		current.Set(field, value)
+	}
+	if err := app.Save(current); err != nil {
+		return err
+	}
+
+	record.Load(current.FieldsData())
+	return nil
+}
+
+// failQueue ends a queue record the worker owns as FAILED, along with any
+// other given fields, and its record as ERROR with message.
+func failQueue(app core.App, queue, record *core.Record, fields dbx.Params, message string) error {
+	fields["status"] = "FAILED"
+	fields["worker_id"] = ""
+	fields["lease_expires_at"] = ""
+
+	return app.RunInTransaction(func(txApp core.App) error {
+		if err := updateQueue(txApp, queue, fields); err != nil {
+			return err
+		}
+		return saveRecordFields(txApp, record, map[string]any{"status": "ERROR", "error": message})
+	})
+}
+
+// heartbeat renews the lease until ctx is done. If the worker loses the
+// record, the run is stopped so that it doesn't overwrite the new owner's work.
+func heartbeat(ctx context.Context, app core.App, queueId, workerId string, lease time.Duration) {
+	ticker := time.NewTicker(lease / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			ok, err := renewLease(app, queueId, workerId, lease)
+			if err != nil {
+				app.Logger().Warn("Downloader: failed to renew lease", "job_id", queueId, "error", err)
+				continue
+			}
+			if !ok {
+				app.Logger().Warn("Downloader: lost lease, stopping job", "job_id", queueId)
+				stopRun(queueId)
+				return
+			}
+		}
+	}
+}
+
+// reapExpiredLeases returns records whose worker stopped renewing its lease to
+// PENDING. Records handed off to Oxylabs stay with Oxylabs; if whoever claimed
+// one to complete it stopped, it is handed back so that the callback or the
+// poller can claim it again.
+func reapExpiredLeases(app core.App) {
+	now := types.NowDateTime().String()
+
+	res, err := app.DB().NewQuery(`
+		UPDATE queue
+		SET status = 'PENDING', worker_id = '', lease_expires_at = '', updated = {:now}
+		WHERE status = 'PROCESSING' AND oxylab_job_id = '' AND (lease_expires_at = '' OR lease_expires_at < {:now})
+	`).Bind(dbx.Params{"now": now}).Execute()
+	if err != nil {
+		app.Logger().Error("Downloader: failed to reap expired leases", "error", err)
+		return
+	}
+
+	if reaped, _ := res.RowsAffected(); reaped > 0 {
+		app.Logger().Warn("Downloader: returned jobs with expired leases to the queue", "count", reaped)
+	}
+
+	res, err = app.DB().NewQuery(`
+		UPDATE queue
+		SET worker_id = '', lease_expires_at = '', updated = {:now}
+		WHERE status = 'PROCESSING' AND oxylab_job_id != '' AND worker_id != '' AND (lease_expires_at = '' OR lease_expires_at < {:now})
+	`).Bind(dbx.Params{"now": now}).Execute()
+	if err != nil {
+		app.Logger().Error("Downloader: failed to reap expired Oxylabs claims", "error", err)
+		return
+	}
+
+	if reaped, _ := res.RowsAffected(); reaped > 0 {
+		app.Logger().Warn("Downloader: handed Oxylabs jobs with expired claims back to the poller", "count", reaped)
+	}
+}
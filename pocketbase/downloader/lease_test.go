@@ -0,0 +1,50 @@
+package downloader
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lsherman98/yt-rss/pocketbase/collections"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// A process that died while completing an Oxylabs job used to leave it
+// claimed, so neither the callback nor the poller could take it again.
+func TestReapExpiredLeasesHandsBackOxylabsClaims(t *testing.T) {
+	app := newTestApp(t)
+	_, queue := newQueuedItem(t, app)
+
+	queue.Set("status", "PROCESSING")
+	queue.Set("oxylab_job_id", "oxylabs-job")
+	if err := app.Save(queue); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := claimOxylabsJob(app, queue, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := claimOxylabsJob(app, reload(t, app, queue), time.Minute); !errors.Is(err, ErrOxylabsJobTaken) {
+		t.Fatalf("expected a claimed job to be taken, got %v", err)
+	}
+
+	reapExpiredLeases(app)
+	if worker := reload(t, app, queue).GetString("worker_id"); worker == "" {
+		t.Fatal("expected a claim with a live lease to be kept")
+	}
+
+	expired, _ := types.ParseDateTime(time.Now().Add(-time.Second))
+	if _, err := app.DB().Update(collections.Queue, dbx.Params{"lease_expires_at": expired.String()}, dbx.HashExp{"id": queue.Id}).Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	reapExpiredLeases(app)
+	queue = reload(t, app, queue)
+	if status := queue.GetString("status"); status != "PROCESSING" {
+		t.Errorf("expected the job to stay with Oxylabs, got %s", status)
+	}
+	if err := claimOxylabsJob(app, queue, time.Minute); err != nil {
+		t.Errorf("expected the job to be claimable again, got %v", err)
+	}
+}
@@ -1,36 +1,68 @@
 package downloader
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
-	"strconv"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/lsherman98/yt-rss/pocketbase/collections"
-	"github.com/lsherman98/yt-rss/pocketbase/files"
+	"github.com/lsherman98/yt-rss/pocketbase/fetch_errors"
 	"github.com/lsherman98/yt-rss/pocketbase/oxylabs"
-	"github.com/lsherman98/yt-rss/pocketbase/rss_utils"
+	"github.com/lsherman98/yt-rss/pocketbase/proxy_pool"
+	"github.com/lsherman98/yt-rss/pocketbase/sponsorblock"
 	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
 	"github.com/pocketbase/pocketbase/core"
 	"github.com/pocketbase/pocketbase/tools/routine"
+	"github.com/pocketbase/pocketbase/tools/types"
+	"github.com/wader/goutubedl"
 )
 
 func Init(app *pocketbase.PocketBase) error {
-	numWorkers, err := strconv.ParseInt(os.Getenv("DOWNLOAD_MAX_WORKERS"), 10, 64)
-	if err != nil || numWorkers <= 0 {
-		app.Logger().Info("DOWNLOAD_MAX_WORKERS not set or invalid, defaulting to 2")
-		numWorkers = 2
+	s, err := loadSettings(app)
+	if err != nil {
+		return fmt.Errorf("failed to load downloader settings: %w", err)
 	}
 
-	oxylabClient, err := oxylabs.NewClient()
-	if err != nil || oxylabClient == nil {
-		return fmt.Errorf("failed to initialize oxylabClient: %w", err)
+	var oxylabClient *oxylabs.Client
+	if s.usesFetcher(FetcherOxylabs) {
+		oxylabClient, err = oxylabs.NewClient()
+		if err != nil || oxylabClient == nil {
+			return fmt.Errorf("failed to initialize oxylabClient: %w", err)
+		}
+	}
+
+	scheduling = &s.scheduling
+
+	pool := proxy_pool.New(app)
+	newAttempt := func(queue *core.Record) (*attempt, error) {
+		ytdlpClient, proxyLease, err := setupYtdlpClient(app, pool, queue)
+		if err != nil {
+			return nil, err
+		}
+
+		ytdlp := &ytdlpFetcher{client: ytdlpClient}
+		return &attempt{
+			fetchers: newFetcherChain(app, s.fetchers, oxylabClient, ytdlp),
+			proxy:    proxyLease,
+			ytdlp:    ytdlp,
+		}, nil
 	}
 
 	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
-		resetHangingJobs(app)
+		se.Router.GET("/api/admin/proxies/report", func(e *core.RequestEvent) error {
+			report, err := pool.Report()
+			if err != nil {
+				return e.InternalServerError("failed to build proxy report", nil)
+			}
+			return e.JSON(http.StatusOK, report)
+		}).Bind(apis.RequireSuperuserAuth())
+
 		return se.Next()
 	})
 
@@ -39,11 +71,24 @@ func Init(app *pocketbase.PocketBase) error {
 		defer ticker.Stop()
 
 		for range ticker.C {
-			processQueue(app, oxylabClient, numWorkers)
+			reapExpiredLeases(app)
+			reapFinishedFlights(app)
+			processQueue(app, s, newAttempt)
 		}
 	})
 
-	app.Logger().Info("Downloader initialized", "num_workers", numWorkers)
+	if oxylabClient != nil {
+		routine.FireAndForget(func() {
+			ticker := time.NewTicker(30 * time.Second)
+			defer ticker.Stop()
+
+			for range ticker.C {
+				pollOxylabsJobs(app, s, oxylabClient)
+			}
+		})
+	}
+
+	app.Logger().Info("Downloader initialized", "num_workers", s.numWorkers, "fetchers", s.fetchers)
 	return nil
 }
 
@@ -53,10 +98,14 @@ func AddJob(app core.App, record *core.Record, collection string) error {
 		return err
 	}
 
+	user := record.GetString("user")
+
 	queue := core.NewRecord(queueCollection)
 	queue.Set("record_id", record.Id)
 	queue.Set("collection", collection)
 	queue.Set("status", "PENDING")
+	queue.Set("user", user)
+	queue.Set("priority", activeSchedulingPolicy().priority(app, user, collection))
 	if err := app.Save(queue); err != nil {
 		return err
 	}
@@ -64,111 +113,159 @@ func AddJob(app core.App, record *core.Record, collection string) error {
 	return nil
 }
 
-func processQueue(app *pocketbase.PocketBase, oxylabClient *oxylabs.Client, numWorkers int64) {
-	processingCount, err := app.CountRecords(collections.Queue, dbx.HashExp{"status": "PROCESSING", "oxylab_job_id": ""})
-	if err != nil {
-		app.Logger().Error("Downloader: failed to get processing job count", "error", err)
+func processQueue(app core.App, s *settings, newAttempt func(queue *core.Record) (*attempt, error)) {
+	// workers are counted per instance, other instances have their own pool
+	processingCount := activeRuns()
+	if processingCount >= s.numWorkers {
 		return
 	}
 
-	if processingCount >= numWorkers {
+	availableWorkers := s.numWorkers - processingCount
+
+	pending, err := eligiblePending(app)
+	if err != nil {
+		app.Logger().Error("Downloader: failed to fetch jobs from queue", "error", err)
 		return
 	}
 
-	availableWorkers := numWorkers - processingCount
-
-	queuesToProcess, err := app.FindRecordsByFilter(collections.Queue, "status={:status}", "+updated", int(availableWorkers), 0, dbx.Params{"status": "PENDING"})
+	running, err := runningPerUser(app)
 	if err != nil {
-		app.Logger().Error("Downloader: failed to fetch jobs from queue", "error", err)
+		app.Logger().Error("Downloader: failed to count running jobs per user", "error", err)
 		return
 	}
 
+	queuesToProcess := fairOrder(pending, running, s.scheduling.maxPerUser)
+	if int64(len(queuesToProcess)) > availableWorkers {
+		queuesToProcess = queuesToProcess[:availableWorkers]
+	}
+
 	for _, q := range queuesToProcess {
+		workerId := uuid.New().String()
+		claimed, err := claimQueue(app, q.Id, workerId, s.leaseDuration)
+		if err != nil {
+			app.Logger().Error("Downloader: failed to claim job", "job_id", q.Id, "error", err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		ctx, done := startRun(q.Id)
+
 		routine.FireAndForget(func() {
+			defer done()
+
+			routine.FireAndForget(func() {
+				heartbeat(ctx, app, q.Id, workerId, s.leaseDuration)
+			})
+
 			queue, err := app.FindRecordById(collections.Queue, q.Id)
 			if err != nil {
 				app.Logger().Error("Downloader: failed to refetch queue record", "queue_id", q.Id, "error", err)
 				return
 			}
 
-			if queue.GetString("status") != "PENDING" {
-				return
-			}
-
-			workerId := uuid.New().String()
-			queue.Set("status", "PROCESSING")
-			queue.Set("worker_id", workerId)
-			if err := app.Save(queue); err != nil {
-				app.Logger().Error("Downloader: failed to claim job", "job_id", queue.Id, "error", err)
-				return
-			}
-
 			recordId := queue.GetString("record_id")
 			collection := queue.GetString("collection")
 
 			record, err := app.FindRecordById(collection, recordId)
 			if err != nil {
 				app.Logger().Error("Downloader: failed to find record for job", "record_id", recordId, "collection", collection, "job_id", queue.Id, "error", err)
-				queue.Set("status", "FAILED")
-				queue.Set("worker_id", nil)
-				if err := app.Save(queue); err != nil {
+				err := updateQueue(app, queue, dbx.Params{"status": "FAILED", "worker_id": "", "lease_expires_at": ""})
+				if err != nil {
 					app.Logger().Error("Downloader: failed to update job status to FAILED", "job_id", queue.Id, "error", err)
 				}
 				return
 			}
 
+			a, err := newAttempt(queue)
+			if err != nil {
+				if err := handleJobFailure(app, s, record, queue, err); err != nil {
+					app.Logger().Error("Downloader: failed to record job failure", "job_id", queue.Id, "error", err)
+				}
+				return
+			}
+
 			var jobErr error
 			switch collection {
 			case collections.Jobs:
-				jobErr = processJob(app, oxylabClient, record, queue)
+				jobErr = processJob(ctx, app, a.fetchers, record, queue)
 			case collections.Items:
-				jobErr = processItem(app, oxylabClient, record, queue)
+				jobErr = processItem(ctx, app, a.fetchers, record, queue)
+			}
+			a.releaseProxy(ctx)
+			if ctx.Err() != nil || errors.Is(jobErr, errLostQueue) {
+				app.Logger().Info("Downloader: job stopped", "job_id", queue.Id)
+				return
 			}
 
 			if jobErr != nil {
 				app.Logger().Error("Downloader: job processing failed", "job_id", queue.Id, "error", jobErr)
-				handleJobFailure(app, record, queue, jobErr)
+				if err := handleJobFailure(app, s, record, queue, jobErr); errors.Is(err, errLostQueue) {
+					app.Logger().Info("Downloader: job stopped", "job_id", queue.Id)
+				} else if err != nil {
+					app.Logger().Error("Downloader: failed to record job failure", "job_id", queue.Id, "error", err)
+				}
 				return
 			}
 		})
 	}
 }
 
-func handleJobFailure(app *pocketbase.PocketBase, record *core.Record, queue *core.Record, jobErr error) {
+// handleJobFailure puts the queue record back in the queue with a backoff, or
+// fails it for good on a permanent error or after too many retries. It
+// returns errLostQueue if the worker no longer owns the record.
+func handleJobFailure(app core.App, s *settings, record *core.Record, queue *core.Record, jobErr error) error {
 	retryCount := queue.GetInt("retry_count")
 	maxRetries := 36
+	kind := fetch_errors.KindOf(jobErr)
 
-	queue.Set("retry_count", retryCount+1)
-	queue.Set("last_error", jobErr.Error())
-
-	if retryCount+1 >= maxRetries {
-		app.Logger().Error("Downloader: job failed after max retries", "job_id", queue.Id)
+	fields := dbx.Params{
+		"retry_count":     retryCount + 1,
+		"last_error":      jobErr.Error(),
+		"last_error_kind": string(kind),
+	}
 
-		queue.Set("status", "FAILED")
-		queue.Set("worker_id", nil)
-		if err := app.Save(queue); err != nil {
-			app.Logger().Error("Downloader: failed to save queue record as FAILED", "job_id", queue.Id, "error", err)
+	if kind == fetch_errors.Permanent || retryCount+1 >= maxRetries {
+		if kind == fetch_errors.Permanent {
+			app.Logger().Error("Downloader: job failed with a permanent error", "job_id", queue.Id, "error", jobErr)
+		} else {
+			app.Logger().Error("Downloader: job failed after max retries", "job_id", queue.Id)
 		}
 
-		record.Set("status", "ERROR")
-		record.Set("error", jobErr.Error())
-		if err := app.Save(record); err != nil {
-			app.Logger().Error("Downloader: failed to update record status to ERROR", "record_id", record.Id, "error", err)
-		}
-	} else {
-		app.Logger().Info("Job failed, will retry", "job_id", queue.Id, "retry_count", retryCount+1, "error", jobErr.Error())
+		return failQueue(app, queue, record, fields, fetch_errors.UserMessage(jobErr))
+	}
 
-		queue.Set("status", "PENDING")
-		queue.Set("worker_id", nil)
-		if err := app.Save(queue); err != nil {
-			app.Logger().Error("Failed to save queue record for retry", "job_id", queue.Id, "error", err)
-		}
+	// only a blocked proxy moves the queue record on to the next proxy
+	if kind == fetch_errors.ProxyBlocked {
+		fields["proxy_failures"] = queue.GetInt("proxy_failures") + 1
+	}
+
+	attempt := retryCount + 1
+	if kind == fetch_errors.RateLimited {
+		attempt++
 	}
+	nextAttemptAt, _ := types.ParseDateTime(s.backoff.nextAttemptAt(attempt))
+	app.Logger().Info("Job failed, will retry", "job_id", queue.Id, "retry_count", retryCount+1, "kind", kind, "next_attempt_at", nextAttemptAt, "error", jobErr.Error())
+
+	fields["status"] = "PENDING"
+	fields["worker_id"] = ""
+	fields["lease_expires_at"] = ""
+	fields["next_attempt_at"] = nextAttemptAt.String()
+
+	return app.RunInTransaction(func(txApp core.App) error {
+		if err := updateQueue(txApp, queue, fields); err != nil {
+			return err
+		}
+
+		return saveRecordFields(txApp, record, map[string]any{"next_attempt_at": nextAttemptAt})
+	})
 }
 
-func processJob(app *pocketbase.PocketBase, oxylabClient *oxylabs.Client, job *core.Record, queue *core.Record) error {
+func processJob(ctx context.Context, app core.App, fetchers FetcherChain, job *core.Record, queue *core.Record) error {
 	url := job.GetString("url")
 	user := job.GetString("user")
+	out := outputFor(job)
 
 	monthlyUsage, err := getMonthlyUsage(app, user)
 	if err != nil {
@@ -176,222 +273,185 @@ func processJob(app *pocketbase.PocketBase, oxylabClient *oxylabs.Client, job *c
 		return err
 	}
 
-	job.Set("status", "STARTED")
-	if err := app.Save(job); err != nil {
+	if err := updateRecord(app, queue, job, map[string]any{"status": "STARTED"}); err != nil {
 		return err
 	}
 
-	ytdlpClient, err := setupYtdlpClient(app, queue)
-	if err != nil {
-		return err
+	if download := finishedDownload(app, queue); download != nil {
+		if !checkUsageLimit(app, queue, monthlyUsage, download.GetInt("size"), job) {
+			return nil
+		}
+
+		if err := updateRecord(app, queue, job, map[string]any{"title": download.GetString("title")}); err != nil {
+			return err
+		}
+		return finalizeDownload(app, queue, job, download, nil)
 	}
 
-	result, err := ytdlpClient.GetInfo(url)
+	result, err := fetchers.GetInfo(ctx, url)
 	if err != nil {
 		app.Logger().Error("Downloader: failed to get video info", "job_id", job.Id, "error", err)
 		return err
 	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 
 	fileSize := calculateFileSize(result)
-	ok := checkUsageLimit(app, monthlyUsage, fileSize, job)
+	ok := checkUsageLimit(app, queue, monthlyUsage, fileSize, job)
 	if !ok {
 		return nil
 	}
 
-	job.Set("title", result.Info.Title)
-	job.Set("status", "PROCESSING")
-	if err := app.Save(job); err != nil {
+	if err := updateRecord(app, queue, job, map[string]any{"title": result.Info.Title, "status": "PROCESSING"}); err != nil {
 		return err
 	}
 
-	ok, _ = checkDownloadExists(app, result.Info.ID, job, queue)
-	if ok {
-		updateMonthlyUsage(app, monthlyUsage, monthlyUsage.GetInt("usage"), fileSize)
-		return nil
-	}
-
-	download, err := createDownloadRecord(app, result)
+	download, role, err := joinFlight(app, result, queue, out)
 	if err != nil {
 		return err
 	}
-
-	retryCount := queue.GetInt("retry_count")
-	if retryCount == 0 {
-		resp, err := oxylabClient.Start(result.Info.ID, queue.Id)
-		if err != nil {
-			app.Logger().Error("Downloader: failed to start Oxylabs job", "job_id", job.Id, "error", err)
-		} else {
-			queue.Set("oxylab_job_id", resp.ID)
-			if err := app.Save(queue); err != nil {
-				return err
-			}
-			return nil
-		}
+	switch role {
+	case flightDone:
+		return finalizeDownload(app, queue, job, download, nil)
+	case flightFollower:
+		return waitForFlight(app, queue, download)
 	}
 
-	file, path, err := ytdlpClient.Download(url, result, retryCount)
-	if err != nil {
-		app.Logger().Error("Downloader: ytdlp download failed", "job_id", job.Id, "error", err)
-		return err
-	}
+	attachTranscript(ctx, app, result, download)
+	attachArtwork(ctx, app, result, download)
 
-	download.Set("file", file)
-	download.Set("size", file.Size)
-	if err := app.Save(download); err != nil {
-		return err
-	}
-
-	err = os.Remove(path)
+	fetched, err := startFetch(ctx, app, fetchers, url, result, queue, out)
 	if err != nil {
-		app.Logger().Error("Downloader: failed to delete converted file", "job_id", job.Id, "error", err)
-	}
-
-	job.Set("download", download.Id)
-	job.Set("status", "SUCCESS")
-	if err := app.Save(job); err != nil {
+		app.Logger().Error("Downloader: download failed", "job_id", job.Id, "error", err)
 		return err
 	}
-
-	queue.Set("status", "COMPLETED")
-	if err := app.Save(queue); err != nil {
-		app.Logger().Error("Downloader: failed to update job status to COMPLETED", "job_id", queue.Id, "error", err)
+	if fetched.Pending {
+		return nil
+	}
+	if ctx.Err() != nil {
+		os.Remove(fetched.Path)
+		return ctx.Err()
 	}
 
-	updateMonthlyUsage(app, monthlyUsage, monthlyUsage.GetInt("usage"), fileSize)
-
-	return nil
+	return finalizeDownload(app, queue, job, download, fetched)
 }
 
-func processItem(app *pocketbase.PocketBase, oxylabClient *oxylabs.Client, item *core.Record, queue *core.Record) error {
+func processItem(ctx context.Context, app core.App, fetchers FetcherChain, item *core.Record, queue *core.Record) error {
 	url := item.GetString("url")
 	podcastId := item.GetString("podcast")
 	user := item.GetString("user")
 
-	podcast, err := app.FindRecordById(collections.Podcasts, podcastId)
+	podcastRecord, err := app.FindRecordById(collections.Podcasts, podcastId)
 	if err != nil {
 		return err
 	}
+	out := outputFor(podcastRecord)
 
-	fileClient, err := files.NewFileClient(app, podcast, "file")
+	monthlyUsage, err := getMonthlyUsage(app, user)
 	if err != nil {
 		return err
 	}
 
-	content, err := fileClient.GetXMLFile()
-	if err != nil {
-		return err
-	}
+	if download := finishedDownload(app, queue); download != nil {
+		if err := updateRecord(app, queue, item, map[string]any{"title": download.GetString("title")}); err != nil {
+			return err
+		}
 
-	p, err := rss_utils.ParseXML(content.String())
-	if err != nil {
-		return err
-	}
+		if !checkUsageLimit(app, queue, monthlyUsage, download.GetInt("size"), item) {
+			return nil
+		}
 
-	monthlyUsage, err := getMonthlyUsage(app, user)
-	if err != nil {
-		return err
+		return finalizeDownload(app, queue, item, download, nil)
 	}
 
-	ytdlpClient, err := setupYtdlpClient(app, queue)
+	result, err := fetchers.GetInfo(ctx, url)
 	if err != nil {
 		return err
 	}
-
-	result, err := ytdlpClient.GetInfo(url)
-	if err != nil {
-		return err
+	if ctx.Err() != nil {
+		return ctx.Err()
 	}
 
-	item.Set("title", result.Info.Title)
-	if err := app.Save(item); err != nil {
+	if err := updateRecord(app, queue, item, map[string]any{"title": result.Info.Title}); err != nil {
 		return err
 	}
 
 	fileSize := calculateFileSize(result)
-	ok := checkUsageLimit(app, monthlyUsage, fileSize, item)
+	ok := checkUsageLimit(app, queue, monthlyUsage, fileSize, item)
 	if !ok {
 		return nil
 	}
 
-	ok, download := checkDownloadExists(app, result.Info.ID, item, queue)
-	if ok {
-		updateMonthlyUsage(app, monthlyUsage, monthlyUsage.GetInt("usage"), fileSize)
-
-		audioURL := fileClient.GetFileURL(download, "file")
-		title := download.GetString("title")
-		description := download.GetString("description")
-		duration := download.GetFloat("duration")
-
-		if description == "" {
-			description = "No description available."
-		}
-
-		now := time.Now()
-		rss_utils.AddItemToPodcast(&p, title, audioURL, description, download.Id, audioURL, int64(duration), &now)
-
-		if err := rss_utils.UpdateXMLFile(app, fileClient, p, podcast); err != nil {
-			return err
-		}
-
-		return nil
-	}
-
-	download, err = createDownloadRecord(app, result)
+	download, role, err := joinFlight(app, result, queue, out)
 	if err != nil {
 		return err
 	}
-
-	resp, err := oxylabClient.Start(result.Info.ID, queue.Id)
-	if err == nil {
-		queue.Set("oxylab_job_id", resp.ID)
-		if err := app.Save(queue); err != nil {
-			return err
-		}
-		return nil
-	} else {
-		app.Logger().Error("Downloader: failed to start Oxylabs job", "error", err)
+	switch role {
+	case flightDone:
+		return finalizeDownload(app, queue, item, download, nil)
+	case flightFollower:
+		return waitForFlight(app, queue, download)
 	}
 
-	retryCount := queue.GetInt("retry_count")
-	file, path, err := ytdlpClient.Download(url, result, retryCount)
-	if err != nil {
-		return err
-	}
+	attachTranscript(ctx, app, result, download)
+	attachArtwork(ctx, app, result, download)
 
-	download.Set("file", file)
-	download.Set("size", file.Size)
-	if err := app.Save(download); err != nil {
+	fetched, err := startFetch(ctx, app, fetchers, url, result, queue, out)
+	if err != nil {
 		return err
 	}
-
-	err = os.Remove(path)
-	if err != nil {
-		app.Logger().Error("Downloader: failed to delete converted file", "error", err)
+	if fetched.Pending {
+		return nil
 	}
-
-	audioURL := fileClient.GetFileURL(download, "file")
-	title := download.GetString("title")
-	description := download.GetString("description")
-	duration := download.GetFloat("duration")
-
-	if description == "" {
-		description = "No description available."
+	if ctx.Err() != nil {
+		os.Remove(fetched.Path)
+		return ctx.Err()
 	}
 
-	now := time.Now()
-	rss_utils.AddItemToPodcast(&p, title, audioURL, description, download.Id, audioURL, int64(duration), &now)
+	return finalizeDownload(app, queue, item, download, fetched)
+}
 
-	if err := rss_utils.UpdateXMLFile(app, fileClient, p, podcast); err != nil {
-		return err
+// startFetch runs the fetcher chain for a queue record. When an async fetcher
+// accepts the job, its id is stored on the queue record and the result is
+// marked as pending. SponsorBlock segments are looked up here for synchronous
+// fetchers; Oxylabs jobs look them up once the file is back. A failed lookup
+// is retried, since the file is stored and shared under its skip settings.
+func startFetch(ctx context.Context, app core.App, fetchers FetcherChain, url string, result *goutubedl.Result, queue *core.Record, out output) (*FetchResult, error) {
+	if len(out.processing.SkipCategories) > 0 {
+		cuts, err := sponsorblock.NewSource().Segments(ctx, result.Info.ID, out.processing.SkipCategories)
+		if err != nil {
+			return nil, fetch_errors.New(fetch_errors.Transient, "", fmt.Errorf("failed to look up SponsorBlock segments: %w", err))
+		}
+		out.processing.Cuts = cuts
 	}
 
-	item.Set("download", download.Id)
-	item.Set("status", "SUCCESS")
-	if err := app.Save(item); err != nil {
-		return err
+	fetched, err := fetchers.Fetch(ctx, FetchRequest{
+		URL:        url,
+		Result:     result,
+		QueueID:    queue.Id,
+		RetryCount: queue.GetInt("retry_count"),
+		Profile:    out.profile,
+		Processing: out.processing,
+	})
+	if err != nil {
+		return nil, err
+	}
+	fetched.Cuts = out.processing.Cuts
+
+	// the record leaves the worker until the callback or the poller claims it
+	if fetched.Pending {
+		err := updateQueue(app, queue, dbx.Params{
+			"oxylab_job_id":     fetched.JobID,
+			"oxylab_started_at": types.NowDateTime().String(),
+			"callback_secret":   fetched.CallbackSecret,
+			"worker_id":         "",
+			"lease_expires_at":  "",
+		})
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	updateMonthlyUsage(app, monthlyUsage, monthlyUsage.GetInt("usage"), fileSize)
-
-	return nil
+	return fetched, nil
 }
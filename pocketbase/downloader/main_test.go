@@ -0,0 +1,419 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lsherman98/yt-rss/pocketbase/collections"
+	"github.com/lsherman98/yt-rss/pocketbase/fetch_errors"
+	_ "github.com/lsherman98/yt-rss/pocketbase/migrations"
+	"github.com/lsherman98/yt-rss/pocketbase/proxy_pool"
+	"github.com/lsherman98/yt-rss/pocketbase/rss_utils"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tests"
+	"github.com/wader/goutubedl"
+)
+
+const testVideoId = "dQw4w9WgXcQ"
+
+// failingFetcher fails every lookup and fetch with err.
+type failingFetcher struct {
+	err error
+}
+
+func (f *failingFetcher) Name() string {
+	return "failing"
+}
+
+func (f *failingFetcher) Async() bool {
+	return false
+}
+
+func (f *failingFetcher) GetInfo(ctx context.Context, url string) (*goutubedl.Result, error) {
+	return nil, f.err
+}
+
+func (f *failingFetcher) Fetch(ctx context.Context, req FetchRequest) (*FetchResult, error) {
+	return nil, f.err
+}
+
+func newRecord(t *testing.T, app core.App, collection string, fields map[string]any) *core.Record {
+	t.Helper()
+
+	c, err := app.FindCollectionByNameOrId(collection)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := core.NewRecord(c)
+	record.Load(fields)
+	if err := app.SaveNoValidate(record); err != nil {
+		t.Fatal(err)
+	}
+	return record
+}
+
+// newFixtures writes a local fetcher fixture for the test video and returns
+// its directory.
+func newFixtures(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	info := `{"id": "` + testVideoId + `", "title": "Test Video", "channel": "Test Channel", "duration": 60, "upload_date": "20240102"}`
+	if err := os.WriteFile(filepath.Join(dir, testVideoId+".info.json"), []byte(info), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, testVideoId+".mp3"), []byte("ID3 fake audio"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// newQueuedItem sets up a podcast with an item for the test video and queues
+// it the way the items hooks do.
+func newQueuedItem(t *testing.T, app core.App) (item, queue *core.Record) {
+	t.Helper()
+
+	user := newRecord(t, app, collections.Users, map[string]any{
+		"name":  "Test",
+		"email": "test@example.com",
+	})
+	newRecord(t, app, collections.MonthlyUsage, map[string]any{
+		"user":  user.Id,
+		"usage": 0,
+		"limit": 1 << 30,
+	})
+	podcast := newRecord(t, app, collections.Podcasts, map[string]any{
+		"user":        user.Id,
+		"title":       "Test Podcast",
+		"description": "Episodes",
+		"image":       "cover.png",
+		// keeps the feed from being registered with Pocket Casts
+		"pocketcasts_url": "https://pca.st/test",
+	})
+	item = newRecord(t, app, collections.Items, map[string]any{
+		"user":        user.Id,
+		"podcast":     podcast.Id,
+		"type":        "url",
+		"url":         "https://www.youtube.com/watch?v=" + testVideoId,
+		"status":      "CREATED",
+		"publication": rss_utils.Published,
+	})
+
+	if err := AddJob(app, item, collections.Items); err != nil {
+		t.Fatal(err)
+	}
+	queue, err := app.FindFirstRecordByData(collections.Queue, "record_id", item.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return item, queue
+}
+
+// runQueue runs one pass of the scheduler with the given chain and waits for
+// the claimed records to finish.
+func runQueue(t *testing.T, app core.App, chain FetcherChain) {
+	t.Helper()
+
+	s := &settings{
+		numWorkers:    2,
+		backoff:       loadBackoffPolicy(),
+		leaseDuration: time.Minute,
+		scheduling:    loadSchedulingPolicy(),
+	}
+	processQueue(app, s, func(queue *core.Record) (*attempt, error) {
+		return &attempt{fetchers: chain, proxy: proxy_pool.Direct()}, nil
+	})
+
+	deadline := time.Now().Add(10 * time.Second)
+	for activeRuns() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the queue to be processed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func newTestApp(t *testing.T) *tests.TestApp {
+	t.Helper()
+
+	// fetched files are written relative to the working directory
+	t.Chdir(t.TempDir())
+
+	app, err := tests.NewTestApp(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(app.Cleanup)
+	return app
+}
+
+func reload(t *testing.T, app core.App, record *core.Record) *core.Record {
+	t.Helper()
+
+	current, err := app.FindRecordById(record.Collection().Name, record.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return current
+}
+
+func TestProcessQueueFallsBackToNextFetcher(t *testing.T) {
+	app := newTestApp(t)
+	item, queue := newQueuedItem(t, app)
+
+	runQueue(t, app, FetcherChain{
+		&failingFetcher{err: errors.New("connection reset")},
+		&localFetcher{dir: newFixtures(t)},
+	})
+
+	queue = reload(t, app, queue)
+	if status := queue.GetString("status"); status != "COMPLETED" {
+		t.Fatalf("expected the queue record to be COMPLETED, got %s (%s)", status, queue.GetString("last_error"))
+	}
+
+	item = reload(t, app, item)
+	if status := item.GetString("status"); status != "SUCCESS" {
+		t.Fatalf("expected the item to be SUCCESS, got %s", status)
+	}
+	if title := item.GetString("title"); title != "Test Video" {
+		t.Errorf("expected the item title from the info file, got %q", title)
+	}
+
+	download, err := app.FindRecordById(collections.Downloads, item.GetString("download"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if download.GetString("file") == "" {
+		t.Error("expected the download to have a file")
+	}
+}
+
+func TestProcessQueueRetriesTransientErrors(t *testing.T) {
+	app := newTestApp(t)
+	item, queue := newQueuedItem(t, app)
+
+	// no fixtures, so the info lookup fails with an unclassified error
+	runQueue(t, app, FetcherChain{&localFetcher{dir: t.TempDir()}})
+
+	queue = reload(t, app, queue)
+	if status := queue.GetString("status"); status != "PENDING" {
+		t.Fatalf("expected the queue record to be PENDING, got %s", status)
+	}
+	if retries := queue.GetInt("retry_count"); retries != 1 {
+		t.Errorf("expected 1 retry, got %d", retries)
+	}
+	if kind := queue.GetString("last_error_kind"); kind != string(fetch_errors.Transient) {
+		t.Errorf("expected a transient error, got %q", kind)
+	}
+	if queue.GetString("worker_id") != "" || !queue.GetDateTime("lease_expires_at").IsZero() {
+		t.Error("expected the worker and lease to be released")
+	}
+
+	nextAttempt := queue.GetDateTime("next_attempt_at")
+	if !nextAttempt.Time().After(time.Now()) {
+		t.Errorf("expected the next attempt to be backed off, got %s", nextAttempt)
+	}
+
+	item = reload(t, app, item)
+	if !item.GetDateTime("next_attempt_at").Equal(nextAttempt) {
+		t.Errorf("expected the item to show the next attempt %s, got %s", nextAttempt, item.GetDateTime("next_attempt_at"))
+	}
+	if status := item.GetString("status"); status == "ERROR" {
+		t.Error("expected the item not to fail on a transient error")
+	}
+}
+
+func TestProcessQueueFailsOnPermanentErrors(t *testing.T) {
+	app := newTestApp(t)
+	item, queue := newQueuedItem(t, app)
+
+	const message = "This video is private."
+	runQueue(t, app, FetcherChain{
+		&failingFetcher{err: fetch_errors.New(fetch_errors.Permanent, message, errors.New("private video"))},
+		// never reached, another backend won't make the video available
+		&localFetcher{dir: newFixtures(t)},
+	})
+
+	queue = reload(t, app, queue)
+	if status := queue.GetString("status"); status != "FAILED" {
+		t.Fatalf("expected the queue record to be FAILED, got %s", status)
+	}
+	if kind := queue.GetString("last_error_kind"); kind != string(fetch_errors.Permanent) {
+		t.Errorf("expected a permanent error, got %q", kind)
+	}
+
+	item = reload(t, app, item)
+	if status := item.GetString("status"); status != "ERROR" {
+		t.Fatalf("expected the item to be ERROR, got %s", status)
+	}
+	if msg := item.GetString("error"); msg != message {
+		t.Errorf("expected the error %q, got %q", message, msg)
+	}
+}
+
+// unfetchableFetcher serves the fixtures' info but fails every fetch with err.
+type unfetchableFetcher struct {
+	localFetcher
+	err error
+}
+
+func (f *unfetchableFetcher) Fetch(ctx context.Context, req FetchRequest) (*FetchResult, error) {
+	return nil, f.err
+}
+
+// A permanent fetch error used to be replaced by the plain error of the next
+// fetcher, which was then retried as transient.
+func TestProcessQueueFailsOnPermanentFetchErrors(t *testing.T) {
+	app := newTestApp(t)
+	item, queue := newQueuedItem(t, app)
+
+	const message = "This video is private."
+	runQueue(t, app, FetcherChain{
+		&unfetchableFetcher{
+			localFetcher: localFetcher{dir: newFixtures(t)},
+			err:          fetch_errors.New(fetch_errors.Permanent, message, errors.New("private video")),
+		},
+		&failingFetcher{err: errors.New("connection reset")},
+	})
+
+	queue = reload(t, app, queue)
+	if status := queue.GetString("status"); status != "FAILED" {
+		t.Fatalf("expected the queue record to be FAILED, got %s", status)
+	}
+	if msg := reload(t, app, item).GetString("error"); msg != message {
+		t.Errorf("expected the error %q, got %q", message, msg)
+	}
+}
+
+// interruptedFetcher serves the fixtures and runs afterInfo and afterFetch
+// once the lookup and the fetch are done, like changes arriving while a video
+// downloads.
+type interruptedFetcher struct {
+	localFetcher
+	afterInfo  func()
+	afterFetch func()
+}
+
+func (f *interruptedFetcher) GetInfo(ctx context.Context, url string) (*goutubedl.Result, error) {
+	result, err := f.localFetcher.GetInfo(ctx, url)
+	if f.afterInfo != nil {
+		f.afterInfo()
+	}
+	return result, err
+}
+
+func (f *interruptedFetcher) Fetch(ctx context.Context, req FetchRequest) (*FetchResult, error) {
+	res, err := f.localFetcher.Fetch(ctx, req)
+	if f.afterFetch != nil {
+		f.afterFetch()
+	}
+	return res, err
+}
+
+// editItem changes the item the way the episode editor does.
+func editItem(t *testing.T, app core.App, item *core.Record, fields map[string]any) {
+	t.Helper()
+
+	current := reload(t, app, item)
+	for field, value := range fields {
+		current.Set(field, value)
+	}
+	if err := app.Save(current); err != nil {
+		t.Error(err)
+	}
+}
+
+// A cancel handled by another process doesn't stop the run here, which used
+// to complete the record over the cancel.
+func TestProcessQueueKeepsCancelFromAnotherProcess(t *testing.T) {
+	app := newTestApp(t)
+	item, queue := newQueuedItem(t, app)
+
+	runQueue(t, app, FetcherChain{&interruptedFetcher{
+		localFetcher: localFetcher{dir: newFixtures(t)},
+		afterFetch: func() {
+			_, err := app.DB().Update(
+				collections.Queue,
+				dbx.Params{"status": "CANCELLED", "worker_id": "", "lease_expires_at": ""},
+				dbx.HashExp{"id": queue.Id},
+			).Execute()
+			if err != nil {
+				t.Error(err)
+			}
+
+			editItem(t, app, item, map[string]any{"status": "CANCELLED"})
+		},
+	}})
+
+	if status := reload(t, app, queue).GetString("status"); status != "CANCELLED" {
+		t.Errorf("expected the queue record to stay CANCELLED, got %s", status)
+	}
+	if status := reload(t, app, item).GetString("status"); status != "CANCELLED" {
+		t.Errorf("expected the item to stay CANCELLED, got %s", status)
+	}
+}
+
+func TestProcessQueueKeepsEditsMadeWhileDownloading(t *testing.T) {
+	app := newTestApp(t)
+	item, _ := newQueuedItem(t, app)
+
+	runQueue(t, app, FetcherChain{&interruptedFetcher{
+		localFetcher: localFetcher{dir: newFixtures(t)},
+		afterInfo: func() {
+			editItem(t, app, item, map[string]any{"custom_title": "My Title"})
+		},
+		afterFetch: func() {
+			editItem(t, app, item, map[string]any{"show_notes": "My notes"})
+		},
+	}})
+
+	item = reload(t, app, item)
+	if status := item.GetString("status"); status != "SUCCESS" {
+		t.Fatalf("expected the item to be SUCCESS, got %s", status)
+	}
+	if title := item.GetString("custom_title"); title != "My Title" {
+		t.Errorf("expected the custom title to be kept, got %q", title)
+	}
+	if notes := item.GetString("show_notes"); notes != "My notes" {
+		t.Errorf("expected the show notes to be kept, got %q", notes)
+	}
+}
+
+func TestProcessQueueKeepsEditsOnRetry(t *testing.T) {
+	app := newTestApp(t)
+	item, queue := newQueuedItem(t, app)
+
+	// the lookup works but there is no file to fetch
+	dir := newFixtures(t)
+	if err := os.Remove(filepath.Join(dir, testVideoId+".mp3")); err != nil {
+		t.Fatal(err)
+	}
+
+	runQueue(t, app, FetcherChain{&interruptedFetcher{
+		localFetcher: localFetcher{dir: dir},
+		afterFetch: func() {
+			editItem(t, app, item, map[string]any{"custom_title": "My Title", "publication": rss_utils.Draft})
+		},
+	}})
+
+	if status := reload(t, app, queue).GetString("status"); status != "PENDING" {
+		t.Fatalf("expected the queue record to be PENDING, got %s", status)
+	}
+
+	item = reload(t, app, item)
+	if title := item.GetString("custom_title"); title != "My Title" {
+		t.Errorf("expected the custom title to be kept, got %q", title)
+	}
+	if publication := item.GetString("publication"); publication != rss_utils.Draft {
+		t.Errorf("expected the item to stay a draft, got %q", publication)
+	}
+	if item.GetDateTime("next_attempt_at").IsZero() {
+		t.Error("expected the item to show the next attempt")
+	}
+}
@@ -0,0 +1,41 @@
+package downloader
+
+import (
+	"strconv"
+
+	"github.com/lsherman98/yt-rss/pocketbase/audio_profiles"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// output is how a record wants its audio: the encoding profile and any
+// post-processing. Downloads are shared only between records asking for the
+// same output.
+type output struct {
+	profile    audio_profiles.Profile
+	processing audio_profiles.Processing
+}
+
+// outputFor reads the output settings of a jobs record or a podcast, which
+// share the audio_profile, loudness_target, trim_silence and speed fields.
+// SponsorBlock cuts are only set on podcasts.
+func outputFor(r *core.Record) output {
+	speed, _ := strconv.ParseFloat(r.GetString("speed"), 64)
+
+	return output{
+		profile: audio_profiles.Resolve(r.GetString("audio_profile")),
+		processing: audio_profiles.Processing{
+			LoudnessTarget: r.GetFloat("loudness_target"),
+			TrimSilence:    r.GetBool("trim_silence"),
+			Speed:          speed,
+			SkipCategories: r.GetStringSlice("sponsorblock_categories"),
+		},
+	}
+}
+
+// duration is how long the encoded file plays for, in seconds.
+func (o output) duration(seconds float64) float64 {
+	if o.processing.Speed > 0 {
+		return seconds / o.processing.Speed
+	}
+	return seconds
+}
@@ -0,0 +1,274 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lsherman98/yt-rss/pocketbase/audio_profiles"
+	"github.com/lsherman98/yt-rss/pocketbase/collections"
+	"github.com/lsherman98/yt-rss/pocketbase/oxylabs"
+	"github.com/lsherman98/yt-rss/pocketbase/sponsorblock"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+	"github.com/pocketbase/pocketbase/tools/routine"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// ErrOxylabsJobTaken is returned when the callback and the poller race for
+// the same job and the other one won.
+var ErrOxylabsJobTaken = errors.New("oxylabs job is already being handled")
+
+// claimOxylabsJob takes a queue record handed off to Oxylabs so that only one
+// of the callback and the poller finishes it. Handed off records have no
+// worker until they are claimed. The claim is leased like a worker's, so a
+// claim whose process died is handed back by reapExpiredLeases.
+func claimOxylabsJob(app core.App, queue *core.Record, lease time.Duration) error {
+	workerId := uuid.New().String()
+	expires, _ := types.ParseDateTime(time.Now().Add(lease))
+
+	res, err := app.DB().Update(
+		collections.Queue,
+		dbx.Params{"worker_id": workerId, "lease_expires_at": expires.String()},
+		dbx.HashExp{"id": queue.Id, "status": "PROCESSING", "oxylab_job_id": queue.GetString("oxylab_job_id"), "worker_id": ""},
+	).Execute()
+	if err != nil {
+		return err
+	}
+
+	if claimed, _ := res.RowsAffected(); claimed != 1 {
+		return ErrOxylabsJobTaken
+	}
+
+	queue.Set("worker_id", workerId)
+	queue.Set("lease_expires_at", expires)
+	return nil
+}
+
+// releaseOxylabsJob hands a claimed job back so that it can be tried again.
+func releaseOxylabsJob(app core.App, queue *core.Record) {
+	_, err := app.DB().Update(
+		collections.Queue,
+		dbx.Params{"worker_id": "", "lease_expires_at": ""},
+		dbx.HashExp{"id": queue.Id, "worker_id": queue.GetString("worker_id")},
+	).Execute()
+	if err != nil {
+		app.Logger().Error("Downloader: failed to release Oxylabs job", "job_id", queue.Id, "error", err)
+	}
+}
+
+// CompleteOxylabsJob downloads the finished Oxylabs file and completes the
+// queue record. It is shared by the webhook and the status poller. The claim
+// is kept alive by a heartbeat while the file downloads and encodes.
+func CompleteOxylabsJob(app core.App, client *oxylabs.Client, queue *core.Record) (err error) {
+	lease := loadLeaseDuration()
+	if err := claimOxylabsJob(app, queue, lease); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			releaseOxylabsJob(app, queue)
+		}
+	}()
+
+	ctx, done := startRun(queue.Id)
+	defer done()
+
+	workerId := queue.GetString("worker_id")
+	routine.FireAndForget(func() {
+		heartbeat(ctx, app, queue.Id, workerId, lease)
+	})
+
+	collection := queue.GetString("collection")
+	recordId := queue.GetString("record_id")
+	record, err := app.FindRecordById(collection, recordId)
+	if err != nil {
+		return fmt.Errorf("failed to find record for done job: %w", err)
+	}
+
+	download, err := app.FindRecordById(collections.Downloads, queue.GetString("download"))
+	if err != nil {
+		return fmt.Errorf("failed to find download record: %w", err)
+	}
+
+	videoId := download.GetString("video_id")
+	jobId := queue.GetString("oxylab_job_id")
+
+	// Oxylabs always delivers AAC in an m4a container
+	profile := audio_profiles.Resolve(download.GetString("profile"))
+	processing := audio_profiles.ParseProcessing(download.GetString("processing"))
+	if len(processing.SkipCategories) > 0 {
+		// the job is left for the next poll, since the file is stored and
+		// shared under its skip settings
+		lookupCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		cuts, err := sponsorblock.NewSource().Segments(lookupCtx, videoId, processing.SkipCategories)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to look up SponsorBlock segments: %w", err)
+		}
+		processing.Cuts = cuts
+	}
+
+	path, err := client.DownloadFile(ctx, videoId, jobId)
+	if err != nil {
+		return fmt.Errorf("failed to download file: %w", err)
+	}
+
+	// the job may have been cancelled while the file was downloading
+	current, err := app.FindRecordById(collections.Queue, queue.Id)
+	if err != nil || current.GetString("status") == "CANCELLED" {
+		removeTempFile(app, path)
+		return nil
+	}
+
+	originalPath := ""
+	if len(processing.SkipCategories) > 0 {
+		originalPath = path
+	}
+
+	if profile.Extension != "m4a" || !processing.IsZero() {
+		encodedPath := strings.TrimSuffix(path, filepath.Ext(path)) + "_" + profile.Name + "." + profile.Extension
+		err := audio_profiles.Transcode(path, encodedPath, profile, processing)
+		if originalPath == "" {
+			removeTempFile(app, path)
+		}
+		if err != nil {
+			removeTempFile(app, originalPath)
+			removeTempFile(app, encodedPath)
+			return fmt.Errorf("failed to encode file: %w", err)
+		}
+		path = encodedPath
+	}
+
+	file, err := filesystem.NewFileFromPath(path)
+	if err != nil {
+		removeTempFile(app, originalPath)
+		removeTempFile(app, path)
+		return err
+	}
+
+	err = finalizeDownload(app, queue, record, download, &FetchResult{
+		Fetcher:      FetcherOxylabs,
+		File:         file,
+		Path:         path,
+		OriginalPath: originalPath,
+		Cuts:         processing.Cuts,
+	})
+	if errors.Is(err, errLostQueue) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := client.DeleteFile(videoId, jobId); err != nil {
+		app.Logger().Warn("Downloader: failed to delete Oxylabs file from storage", "job_id", queue.Id, "error", err)
+	}
+
+	return nil
+}
+
+// FallBackFromOxylabs gives up on an Oxylabs job and puts the queue record back
+// in the queue as a retry, which skips async fetchers and goes to yt-dlp. If
+// no synchronous fetcher is configured the record fails instead. The callback
+// secret is kept so that a late callback for the abandoned job is still
+// authenticated, and then ignored because its job id no longer matches.
+func FallBackFromOxylabs(app core.App, queue *core.Record, reason string) error {
+	if err := claimOxylabsJob(app, queue, loadLeaseDuration()); err != nil {
+		return err
+	}
+
+	fetchers, _ := parseFetcherNames(os.Getenv("DOWNLOAD_FETCHERS"))
+	hasSyncFetcher := false
+	for _, name := range fetchers {
+		if name != FetcherOxylabs {
+			hasSyncFetcher = true
+		}
+	}
+
+	fields := dbx.Params{
+		"oxylab_job_id":     "",
+		"oxylab_started_at": "",
+		"last_error":        reason,
+		"retry_count":       queue.GetInt("retry_count") + 1,
+	}
+
+	if hasSyncFetcher {
+		app.Logger().Warn("Downloader: falling back from Oxylabs", "job_id", queue.Id, "reason", reason)
+		fields["status"] = "PENDING"
+		fields["worker_id"] = ""
+		fields["lease_expires_at"] = ""
+		fields["next_attempt_at"] = ""
+		return updateQueue(app, queue, fields)
+	}
+
+	record, err := app.FindRecordById(queue.GetString("collection"), queue.GetString("record_id"))
+	if err != nil {
+		fields["status"] = "FAILED"
+		fields["worker_id"] = ""
+		fields["lease_expires_at"] = ""
+		return errors.Join(err, updateQueue(app, queue, fields))
+	}
+
+	return failQueue(app, queue, record, fields, reason)
+}
+
+// pollOxylabsJobs checks the status of jobs whose callback is overdue and
+// finishes them the same way the webhook would. Jobs that are still pending
+// after the timeout fall back to yt-dlp, and so do done jobs that still can't
+// be completed by then.
+func pollOxylabsJobs(app core.App, s *settings, client *oxylabs.Client) {
+	overdue, _ := types.ParseDateTime(time.Now().Add(-s.oxylabsPollAfter))
+
+	queues, err := app.FindRecordsByFilter(
+		collections.Queue,
+		"status = 'PROCESSING' && oxylab_job_id != '' && worker_id = '' && oxylab_started_at <= {:overdue}",
+		"+oxylab_started_at",
+		50,
+		0,
+		dbx.Params{"overdue": overdue.String()},
+	)
+	if err != nil {
+		app.Logger().Error("Downloader: failed to find overdue Oxylabs jobs", "error", err)
+		return
+	}
+
+	for _, queue := range queues {
+		timedOut := time.Since(queue.GetDateTime("oxylab_started_at").Time()) > s.oxylabsTimeout
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		status, err := client.JobStatus(ctx, queue.GetString("oxylab_job_id"))
+		cancel()
+
+		if err != nil {
+			app.Logger().Warn("Downloader: failed to check Oxylabs job status", "job_id", queue.Id, "error", err)
+			if !timedOut {
+				continue
+			}
+		}
+
+		switch {
+		case err == nil && status.Status == "done":
+			app.Logger().Info("Downloader: Oxylabs callback overdue, completing from status poll", "job_id", queue.Id)
+			err = CompleteOxylabsJob(app, client, queue)
+			if err != nil && timedOut && !errors.Is(err, ErrOxylabsJobTaken) {
+				app.Logger().Error("Downloader: failed to complete timed out Oxylabs job", "job_id", queue.Id, "error", err)
+				err = FallBackFromOxylabs(app, queue, "Oxylabs job could not be completed")
+			}
+		case err == nil && status.Status == "faulted":
+			err = FallBackFromOxylabs(app, queue, "Oxylabs job faulted")
+		case timedOut:
+			err = FallBackFromOxylabs(app, queue, "Oxylabs job timed out")
+		}
+
+		if err != nil && !errors.Is(err, ErrOxylabsJobTaken) {
+			app.Logger().Error("Downloader: failed to finish overdue Oxylabs job", "job_id", queue.Id, "error", err)
+		}
+	}
+}
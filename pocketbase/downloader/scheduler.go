@@ -0,0 +1,270 @@
+package downloader
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lsherman98/yt-rss/pocketbase/collections"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// maxScheduledRecords bounds how many PENDING records are considered on a
+// single pass of the scheduler.
+const maxScheduledRecords = 1000
+
+var defaultTierWeights = map[string]float64{
+	"free":         1,
+	"basic":        2,
+	"power_user":   4,
+	"professional": 8,
+}
+
+type schedulingPolicy struct {
+	tierWeights map[string]float64
+	itemsWeight float64
+	maxPerUser  int
+}
+
+// scheduling is the policy of this process's workers, set by Init, so that
+// the priority given at enqueue time and the estimated positions agree with
+// how the workers pick records.
+var scheduling *schedulingPolicy
+
+func activeSchedulingPolicy() schedulingPolicy {
+	if scheduling != nil {
+		return *scheduling
+	}
+	return loadSchedulingPolicy()
+}
+
+// loadSchedulingPolicy reads DOWNLOAD_TIER_WEIGHTS ("free=1,professional=8"),
+// DOWNLOAD_ITEMS_WEIGHT and DOWNLOAD_MAX_PER_USER. Tier weights are keyed by
+// the plan part of the tier's lookup_key, e.g. "basic" for "basic_monthly".
+func loadSchedulingPolicy() schedulingPolicy {
+	policy := schedulingPolicy{
+		tierWeights: map[string]float64{},
+		itemsWeight: 2,
+		maxPerUser:  2,
+	}
+
+	for tier, weight := range defaultTierWeights {
+		policy.tierWeights[tier] = weight
+	}
+	for _, pair := range strings.Split(os.Getenv("DOWNLOAD_TIER_WEIGHTS"), ",") {
+		tier, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			continue
+		}
+		if weight, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && weight > 0 {
+			policy.tierWeights[strings.TrimSpace(tier)] = weight
+		}
+	}
+
+	if w, err := strconv.ParseFloat(os.Getenv("DOWNLOAD_ITEMS_WEIGHT"), 64); err == nil && w > 0 {
+		policy.itemsWeight = w
+	}
+	if n, err := strconv.Atoi(os.Getenv("DOWNLOAD_MAX_PER_USER")); err == nil && n >= 0 {
+		policy.maxPerUser = n
+	}
+
+	return policy
+}
+
+func (p schedulingPolicy) tierWeight(lookupKey string) float64 {
+	if weight, ok := p.tierWeights[lookupKey]; ok {
+		return weight
+	}
+
+	plan := strings.TrimSuffix(strings.TrimSuffix(lookupKey, "_monthly"), "_yearly")
+	if weight, ok := p.tierWeights[plan]; ok {
+		return weight
+	}
+
+	return 1
+}
+
+// priority is the weight a queue record gets for its owner's tier, boosted for
+// items since those are added interactively from the podcast page.
+func (p schedulingPolicy) priority(app core.App, userId string, collection string) float64 {
+	weight := 1.0
+
+	user, err := app.FindRecordById(collections.Users, userId)
+	if err == nil {
+		tier, err := app.FindRecordById(collections.SubscriptionTiers, user.GetString("tier"))
+		if err == nil {
+			weight = p.tierWeight(tier.GetString("lookup_key"))
+		}
+	}
+
+	if collection == collections.Items {
+		weight *= p.itemsWeight
+	}
+
+	return weight
+}
+
+// fairOrder orders pending queue records using weighted fair queuing across
+// users: each turn goes to the user with the lowest (served + 1) / weight,
+// where served counts the user's running records plus those already picked.
+// Within a user, higher priority records go first, then older ones. Users at
+// maxPerUser are skipped, pass 0 to order every record.
+func fairOrder(pending []*core.Record, running map[string]int, maxPerUser int) []*core.Record {
+	byUser := map[string][]*core.Record{}
+	users := []string{}
+	for _, r := range pending {
+		user := r.GetString("user")
+		if _, ok := byUser[user]; !ok {
+			users = append(users, user)
+		}
+		byUser[user] = append(byUser[user], r)
+	}
+
+	for _, records := range byUser {
+		sort.SliceStable(records, func(i, j int) bool {
+			pi, pj := recordPriority(records[i]), recordPriority(records[j])
+			if pi != pj {
+				return pi > pj
+			}
+			return records[i].GetString("created") < records[j].GetString("created")
+		})
+	}
+
+	served := map[string]int{}
+	for _, user := range users {
+		served[user] = running[user]
+	}
+
+	ordered := make([]*core.Record, 0, len(pending))
+	for {
+		next := ""
+		nextTag := 0.0
+		for _, user := range users {
+			records := byUser[user]
+			if len(records) == 0 {
+				continue
+			}
+			if maxPerUser > 0 && served[user] >= maxPerUser {
+				continue
+			}
+
+			tag := float64(served[user]+1) / recordPriority(records[0])
+			if next == "" || tag < nextTag || (tag == nextTag && records[0].GetString("created") < byUser[next][0].GetString("created")) {
+				next = user
+				nextTag = tag
+			}
+		}
+
+		if next == "" {
+			return ordered
+		}
+
+		ordered = append(ordered, byUser[next][0])
+		byUser[next] = byUser[next][1:]
+		served[next]++
+	}
+}
+
+func recordPriority(r *core.Record) float64 {
+	if p := r.GetFloat("priority"); p > 0 {
+		return p
+	}
+	return 1
+}
+
+// runningPerUser counts the records each user has with a worker right now.
+func runningPerUser(app core.App) (map[string]int, error) {
+	rows := []struct {
+		User  string `db:"user"`
+		Count int    `db:"count"`
+	}{}
+
+	err := app.DB().
+		Select("user", "COUNT(*) AS count").
+		From(collections.Queue).
+		Where(dbx.HashExp{"status": "PROCESSING", "oxylab_job_id": ""}).
+		GroupBy("user").
+		All(&rows)
+	if err != nil {
+		return nil, err
+	}
+
+	running := map[string]int{}
+	for _, row := range rows {
+		running[row.User] = row.Count
+	}
+	return running, nil
+}
+
+// eligiblePending returns the PENDING records the scheduler can pick from,
+// those whose backoff has passed, oldest first.
+func eligiblePending(app core.App) ([]*core.Record, error) {
+	return app.FindRecordsByFilter(
+		collections.Queue,
+		"status={:status} && (next_attempt_at='' || next_attempt_at<={:now})",
+		"+created",
+		maxScheduledRecords,
+		0,
+		dbx.Params{"status": "PENDING", "now": types.NowDateTime().String()},
+	)
+}
+
+// positionsTTL is how long estimated positions are reused. Clients poll for
+// them, and ordering the queue on every poll would be wasted work.
+const positionsTTL = 5 * time.Second
+
+var positionsCache struct {
+	sync.Mutex
+	at        time.Time
+	positions map[string]int
+}
+
+// QueuePositions estimates where each PENDING record will be picked up, keyed
+// by the jobs or items record id. Positions start at 1. Records waiting out a
+// backoff have no position. Records of users at the per-user limit come after
+// the rest, since they wait for one of their user's records to finish.
+func QueuePositions(app core.App) (map[string]int, error) {
+	positionsCache.Lock()
+	defer positionsCache.Unlock()
+
+	if positionsCache.positions != nil && time.Since(positionsCache.at) < positionsTTL {
+		return positionsCache.positions, nil
+	}
+
+	pending, err := eligiblePending(app)
+	if err != nil {
+		return nil, err
+	}
+
+	running, err := runningPerUser(app)
+	if err != nil {
+		return nil, err
+	}
+
+	ordered := fairOrder(pending, running, activeSchedulingPolicy().maxPerUser)
+	picked := map[string]bool{}
+	for _, r := range ordered {
+		picked[r.Id] = true
+	}
+	capped := []*core.Record{}
+	for _, r := range pending {
+		if !picked[r.Id] {
+			capped = append(capped, r)
+		}
+	}
+	ordered = append(ordered, fairOrder(capped, running, 0)...)
+
+	positions := map[string]int{}
+	for i, r := range ordered {
+		positions[r.GetString("record_id")] = i + 1
+	}
+
+	positionsCache.at = time.Now()
+	positionsCache.positions = positions
+	return positions, nil
+}
@@ -0,0 +1,40 @@
+package downloader
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lsherman98/yt-rss/pocketbase/collections"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Records waiting out a backoff aren't picked up, so they used to be given
+// positions ahead of records that were.
+func TestQueuePositionsSkipsBackedOffRecords(t *testing.T) {
+	app := newTestApp(t)
+	item, queue := newQueuedItem(t, app)
+
+	positionsCache.positions = nil
+	positions, err := QueuePositions(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if positions[item.Id] != 1 {
+		t.Fatalf("expected the item to be first, got %d", positions[item.Id])
+	}
+
+	later, _ := types.ParseDateTime(time.Now().Add(time.Hour))
+	if _, err := app.DB().Update(collections.Queue, dbx.Params{"next_attempt_at": later.String()}, dbx.HashExp{"id": queue.Id}).Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	positionsCache.positions = nil
+	positions, err = QueuePositions(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if position, ok := positions[item.Id]; ok {
+		t.Errorf("expected a backed off item to have no position, got %d", position)
+	}
+}
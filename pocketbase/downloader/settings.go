@@ -0,0 +1,72 @@
+package downloader
+
+import (
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+type settings struct {
+	numWorkers int64
+	fetchers   []string
+	backoff    backoffPolicy
+	// leaseDuration is how long a claimed queue record stays with its worker
+	// without a heartbeat before it is handed to another worker.
+	leaseDuration time.Duration
+	scheduling    schedulingPolicy
+	// oxylabsPollAfter is how long to wait for an Oxylabs callback before
+	// polling the job status, oxylabsTimeout when to give up on the job.
+	oxylabsPollAfter time.Duration
+	oxylabsTimeout   time.Duration
+}
+
+func loadSettings(app core.App) (*settings, error) {
+	numWorkers, err := strconv.ParseInt(os.Getenv("DOWNLOAD_MAX_WORKERS"), 10, 64)
+	if err != nil || numWorkers <= 0 {
+		app.Logger().Info("DOWNLOAD_MAX_WORKERS not set or invalid, defaulting to 2")
+		numWorkers = 2
+	}
+
+	fetchers, err := parseFetcherNames(os.Getenv("DOWNLOAD_FETCHERS"))
+	if err != nil {
+		return nil, err
+	}
+
+	oxylabsPollAfter, err := time.ParseDuration(os.Getenv("DOWNLOAD_OXYLABS_POLL_AFTER"))
+	if err != nil || oxylabsPollAfter <= 0 {
+		oxylabsPollAfter = 2 * time.Minute
+	}
+
+	oxylabsTimeout, err := time.ParseDuration(os.Getenv("DOWNLOAD_OXYLABS_TIMEOUT"))
+	if err != nil || oxylabsTimeout <= 0 {
+		oxylabsTimeout = 20 * time.Minute
+	}
+
+	return &settings{
+		numWorkers:    numWorkers,
+		fetchers:      fetchers,
+		backoff:       loadBackoffPolicy(),
+		leaseDuration: loadLeaseDuration(),
+		scheduling:    loadSchedulingPolicy(),
+
+		oxylabsPollAfter: oxylabsPollAfter,
+		oxylabsTimeout:   oxylabsTimeout,
+	}, nil
+}
+
+// loadLeaseDuration reads DOWNLOAD_LEASE_DURATION. It is also read on its own
+// by the Oxylabs callback, which has no settings.
+func loadLeaseDuration() time.Duration {
+	leaseDuration, err := time.ParseDuration(os.Getenv("DOWNLOAD_LEASE_DURATION"))
+	if err != nil || leaseDuration < 30*time.Second {
+		return 2 * time.Minute
+	}
+	return leaseDuration
+}
+
+func (s *settings) usesFetcher(name string) bool {
+	return slices.Contains(s.fetchers, name)
+}
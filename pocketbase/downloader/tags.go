@@ -0,0 +1,195 @@
+package downloader
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/lsherman98/yt-rss/pocketbase/audio_profiles"
+	"github.com/lsherman98/yt-rss/pocketbase/collections"
+	"github.com/lsherman98/yt-rss/pocketbase/rss_utils"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+)
+
+// tagsFor builds the tags of a download. Its file is shared by every record
+// asking for the same output, across podcasts and users, so it only gets the
+// video's own tags and no album. Items get the album on their own copy, see
+// tagItemFile. The cover is the download's artwork.
+func tagsFor(app core.App, download *core.Record) audio_profiles.Tags {
+	tags := audio_profiles.Tags{
+		Title:   download.GetString("title"),
+		Artist:  download.GetString("channel"),
+		Comment: download.GetString("description"),
+	}
+
+	if uploaded := download.GetDateTime("upload_date"); !uploaded.IsZero() {
+		tags.Date = uploaded.Time().Format("2006-01-02")
+	}
+
+	cover, err := readArtwork(app, download)
+	if err != nil {
+		app.Logger().Warn("Downloader: failed to read cover art", "download_id", download.Id, "error", err)
+	}
+	tags.Cover = cover
+
+	return tags
+}
+
+// readArtwork returns the download's stored artwork, or nil if it has none.
+func readArtwork(app core.App, download *core.Record) ([]byte, error) {
+	name := download.GetString("artwork")
+	if name == "" {
+		return nil, nil
+	}
+
+	fsys, err := app.NewFilesystem()
+	if err != nil {
+		return nil, err
+	}
+	defer fsys.Close()
+
+	r, err := fsys.GetReader(download.BaseFilesPath() + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
+
+// tagStoredFile tags a download finished before files were tagged. The file
+// is overwritten under the same name, so feeds linking it keep working, and
+// the other feeds listing it are rebuilt for the new size. The feed of
+// record's podcast is left to the caller, which rebuilds it once the record
+// is done.
+func tagStoredFile(app core.App, record, download *core.Record) error {
+	fsys, err := app.NewFilesystem()
+	if err != nil {
+		return err
+	}
+	defer fsys.Close()
+
+	key := download.BaseFilesPath() + "/" + download.GetString("file")
+	path, err := copyStoredFile(fsys, key)
+	if err != nil {
+		return err
+	}
+	defer removeTempFile(app, path)
+
+	if err := audio_profiles.WriteTags(path, tagsFor(app, download)); err != nil {
+		return err
+	}
+
+	file, err := filesystem.NewFileFromPath(path)
+	if err != nil {
+		return err
+	}
+	if err := fsys.UploadFile(file, key); err != nil {
+		return err
+	}
+
+	download.Set("size", file.Size)
+	download.Set("tagged", true)
+	if err := app.Save(download); err != nil {
+		return err
+	}
+
+	rebuildFeedsListing(app, download, record.GetString("podcast"))
+	return nil
+}
+
+// tagItemFile copies the download's file for an item and tags the copy with
+// the item's podcast title as the album. fetched is the file the item just
+// fetched, or nil to copy the stored file. It returns the copy's temporary
+// path, which the caller stores as the item's file and then removes. Feeds
+// serve the item's file instead of the download's when it has one.
+func tagItemFile(app core.App, item, download *core.Record, fetched *FetchResult) (string, error) {
+	podcastRecord, err := app.FindRecordById(collections.Podcasts, item.GetString("podcast"))
+	if err != nil {
+		return "", err
+	}
+
+	var path string
+	if fetched != nil {
+		ext := filepath.Ext(fetched.Path)
+		path = strings.TrimSuffix(fetched.Path, ext) + "_" + item.Id + ext
+		err = copyFile(fetched.Path, path)
+	} else {
+		fsys, fsErr := app.NewFilesystem()
+		if fsErr != nil {
+			return "", fsErr
+		}
+		defer fsys.Close()
+		path, err = copyStoredFile(fsys, download.BaseFilesPath()+"/"+download.GetString("file"))
+	}
+	if err != nil {
+		return "", err
+	}
+
+	tags := tagsFor(app, download)
+	tags.Album = podcastRecord.GetString("title")
+	if err := audio_profiles.WriteTags(path, tags); err != nil {
+		removeTempFile(app, path)
+		return "", err
+	}
+
+	return path, nil
+}
+
+// copyStoredFile copies a stored file to a temporary file and returns its path.
+func copyStoredFile(fsys *filesystem.System, key string) (string, error) {
+	r, err := fsys.GetReader(key)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	tmp, err := os.CreateTemp("", "tag-*"+filepath.Ext(key))
+	if err != nil {
+		return "", err
+	}
+
+	_, err = io.Copy(tmp, r)
+	tmp.Close()
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// rebuildFeedsListing rebuilds the feeds of every podcast with a finished
+// episode for the download, except skip, since they list its size as the
+// enclosure length.
+func rebuildFeedsListing(app core.App, download *core.Record, skip string) {
+	items, err := app.FindAllRecords(
+		collections.Items,
+		dbx.HashExp{"download": download.Id, "status": "SUCCESS"},
+	)
+	if err != nil {
+		app.Logger().Error("Downloader: failed to find items of download", "download_id", download.Id, "error", err)
+		return
+	}
+
+	podcastIds := []string{}
+	for _, item := range items {
+		if id := item.GetString("podcast"); id != skip && !slices.Contains(podcastIds, id) {
+			podcastIds = append(podcastIds, id)
+		}
+	}
+
+	for _, id := range podcastIds {
+		podcastRecord, err := app.FindRecordById(collections.Podcasts, id)
+		if err != nil {
+			app.Logger().Error("Downloader: failed to find podcast of download", "podcast_id", id, "error", err)
+			continue
+		}
+		if err := rss_utils.RebuildFeed(app, podcastRecord); err != nil {
+			app.Logger().Error("Downloader: failed to rebuild feed", "podcast_id", id, "error", err)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/lsherman98/yt-rss/pocketbase/transcripts"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/wader/goutubedl"
+)
+
+var transcriptClient = &http.Client{Timeout: 30 * time.Second}
+
+// attachTranscript stores the video's subtitles on the download. They are
+// kept on the video's timeline and retimed for cuts and speed when served.
+// A video without subtitles, or whose subtitles can't be fetched, is still
+// downloaded.
+func attachTranscript(ctx context.Context, app core.App, result *goutubedl.Result, download *core.Record) {
+	track, ok := transcripts.Pick(result.RawJSON)
+	if !ok {
+		return
+	}
+
+	file, err := transcripts.Download(ctx, transcriptClient, track, result.Info.ID)
+	if err != nil {
+		app.Logger().Warn("Downloader: failed to fetch transcript", "video_id", result.Info.ID, "language", track.Language, "error", err)
+		return
+	}
+
+	download.Set("transcript", file)
+	download.Set("transcript_language", track.Language)
+	if err := app.Save(download); err != nil {
+		app.Logger().Warn("Downloader: failed to save transcript", "video_id", result.Info.ID, "error", err)
+	}
+}
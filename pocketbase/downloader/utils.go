@@ -2,54 +2,50 @@ package downloader
 
 import (
 	"fmt"
+	"os"
+	"time"
 
+	"github.com/lsherman98/yt-rss/pocketbase/chapters"
 	"github.com/lsherman98/yt-rss/pocketbase/collections"
+	"github.com/lsherman98/yt-rss/pocketbase/fetch_errors"
+	"github.com/lsherman98/yt-rss/pocketbase/proxy_pool"
 	"github.com/lsherman98/yt-rss/pocketbase/ytdlp"
 	"github.com/pocketbase/dbx"
-	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/core"
 	"github.com/wader/goutubedl"
 )
 
-func resetHangingJobs(app *pocketbase.PocketBase) {
-	hangingQueues, err := app.FindRecordsByFilter(collections.Queue, "status={:status}", "", 0, 0, dbx.Params{"status": "PROCESSING"})
-	if err != nil {
-		app.Logger().Error("Downloader: failed to query for hanging jobs", "error", err)
-		return
-	}
-
-	for _, q := range hangingQueues {
-		q.Set("status", "PENDING")
-		q.Set("worker_id", nil)
-		if err := app.Save(q); err != nil {
-			app.Logger().Error("Downloader: failed to reset hanging job", "job_id", q.Id, "error", err)
-		}
-	}
-}
-
-func setupYtdlpClient(app *pocketbase.PocketBase, queue *core.Record) (*ytdlp.Client, error) {
+// setupYtdlpClient checks out a proxy for the attempt. The proxy of the last
+// attempt is kept unless it was blocked, in which case another one is picked.
+func setupYtdlpClient(app core.App, pool *proxy_pool.Pool, queue *core.Record) (*ytdlp.Client, *proxy_pool.Lease, error) {
 	ytdlpClient := ytdlp.New(app)
 	if ytdlpClient == nil {
-		return nil, fmt.Errorf("failed to initialize ytdlp client")
+		return nil, nil, fmt.Errorf("failed to initialize ytdlp client")
 	}
 
-	retryCount := queue.GetInt("retry_count")
-	if retryCount > 0 {
-		ytdlpClient.SwitchProxy(retryCount)
-	} else {
-		return ytdlpClient, nil
+	lease := proxy_pool.Direct()
+	if os.Getenv("DEV") != "true" {
+		lastProxy := queue.GetString("last_proxy")
+		exclude := ""
+		if queue.GetString("last_error_kind") == string(fetch_errors.ProxyBlocked) {
+			exclude = lastProxy
+		}
+		var err error
+		lease, err = pool.Acquire(lastProxy, exclude)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
+	ytdlpClient.SetProxy(lease.Label, lease.URL)
 
-	currentProxy := ytdlpClient.GetCurrentProxyKey()
-	queue.Set("last_proxy", currentProxy)
-	if err := app.Save(queue); err != nil {
+	if err := updateQueue(app, queue, dbx.Params{"last_proxy": lease.Label}); err != nil {
 		app.Logger().Warn("Downloader: failed to update queue record with proxy info", "error", err)
 	}
 
-	return ytdlpClient, nil
+	return ytdlpClient, lease, nil
 }
 
-func getMonthlyUsage(app *pocketbase.PocketBase, user string) (*core.Record, error) {
+func getMonthlyUsage(app core.App, user string) (*core.Record, error) {
 	usageRecords, err := app.FindRecordsByFilter(collections.MonthlyUsage, "user = {:user}", "-created", 1, 0, dbx.Params{"user": user})
 	if err != nil || len(usageRecords) == 0 {
 		return nil, fmt.Errorf("failed to find monthly usage record: %w", err)
@@ -65,14 +61,15 @@ func calculateFileSize(result *goutubedl.Result) int {
 	return int(float64(length) * 25000)
 }
 
-func checkUsageLimit(app *pocketbase.PocketBase, monthlyUsage *core.Record, fileSize int, record *core.Record) bool {
+// checkUsageLimit fails the queue record and its record when the file would
+// take the user over their monthly limit.
+func checkUsageLimit(app core.App, queue *core.Record, monthlyUsage *core.Record, fileSize int, record *core.Record) bool {
 	usageLimit := monthlyUsage.GetInt("limit")
 	currentUsage := monthlyUsage.GetInt("usage")
 	exceedsLimit := currentUsage > usageLimit || (currentUsage+fileSize) > usageLimit
 	if exceedsLimit {
-		record.Set("status", "ERROR")
-		record.Set("error", "Failed to add item to podcast: Monthly usage limit exceeded")
-		if err := app.Save(record); err != nil {
+		message := "Failed to add item to podcast: Monthly usage limit exceeded"
+		if err := failQueue(app, queue, record, dbx.Params{"last_error": message}, message); err != nil {
 			app.Logger().Error("Downloader: failed to update item record status to ERROR", "error", err)
 		}
 		return false
@@ -80,33 +77,14 @@ func checkUsageLimit(app *pocketbase.PocketBase, monthlyUsage *core.Record, file
 	return true
 }
 
-func checkDownloadExists(app *pocketbase.PocketBase, videoId string, record, queue *core.Record) (bool, *core.Record) {
-	existingDownload, err := app.FindFirstRecordByData(collections.Downloads, "video_id", videoId)
-	if err == nil && existingDownload != nil && existingDownload.Get("file") != "" {
-		record.Set("download", existingDownload.Id)
-		record.Set("status", "SUCCESS")
-		if err := app.Save(record); err != nil {
-			return false, nil
-		}
-
-		queue.Set("status", "COMPLETED")
-		if err := app.Save(queue); err != nil {
-			app.Logger().Error("Downloader: failed to update job status to COMPLETED", "job_id", queue.Id, "error", err)
-		}
-
-		return true, existingDownload
-	}
-	return false, nil
-}
-
-func updateMonthlyUsage(app *pocketbase.PocketBase, monthlyUsage *core.Record, currentUsage, fileSize int) {
+func updateMonthlyUsage(app core.App, monthlyUsage *core.Record, currentUsage, fileSize int) {
 	monthlyUsage.Set("usage", currentUsage+fileSize)
 	if err := app.Save(monthlyUsage); err != nil {
 		app.Logger().Error("Downloader: failed to update monthly usage", "error", err)
 	}
 }
 
-func createDownloadRecord(app *pocketbase.PocketBase, result *goutubedl.Result) (*core.Record, error) {
+func createDownloadRecord(app core.App, result *goutubedl.Result, queue *core.Record, out output) (*core.Record, error) {
 	downloads, err := app.FindCollectionByNameOrId(collections.Downloads)
 	if err != nil {
 		return nil, err
@@ -114,10 +92,25 @@ func createDownloadRecord(app *pocketbase.PocketBase, result *goutubedl.Result)
 
 	download := core.NewRecord(downloads)
 	download.Set("title", result.Info.Title)
-	download.Set("duration", result.Info.Duration)
+	download.Set("duration", out.duration(result.Info.Duration))
 	download.Set("channel", result.Info.Channel)
 	download.Set("description", result.Info.Description)
 	download.Set("video_id", result.Info.ID)
+	if uploaded, err := time.Parse("20060102", result.Info.UploadDate); err == nil {
+		download.Set("upload_date", uploaded)
+	}
+	download.Set("profile", out.profile.Name)
+	download.Set("processing", out.processing.Key())
+	download.Set("fetching_queue", queue.Id)
+
+	// chapters are kept on the video's timeline until the file is encoded,
+	// when cuts and speed are known
+	videoChapters, err := chapters.FromInfoJSON(result.RawJSON)
+	if err != nil {
+		app.Logger().Warn("Downloader: failed to read chapters", "video_id", result.Info.ID, "error", err)
+	}
+	download.Set("chapters", videoChapters)
+
 	if err := app.Save(download); err != nil {
 		return nil, err
 	}
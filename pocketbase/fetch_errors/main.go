@@ -0,0 +1,56 @@
+package fetch_errors
+
+import "errors"
+
+type Kind string
+
+const (
+	// Transient errors may succeed on a plain retry.
+	Transient Kind = "transient"
+	// Permanent errors will never succeed, e.g. a private or deleted video.
+	Permanent Kind = "permanent"
+	// ProxyBlocked errors mean the current proxy was refused and another one should be tried.
+	ProxyBlocked Kind = "proxy_blocked"
+	// RateLimited errors mean the provider asked us to slow down.
+	RateLimited Kind = "rate_limited"
+)
+
+type Error struct {
+	Kind    Kind
+	Message string
+	Err     error
+}
+
+func New(kind Kind, message string, err error) *Error {
+	return &Error{Kind: kind, Message: message, Err: err}
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// KindOf returns the kind of a classified error. Unclassified errors are
+// treated as transient.
+func KindOf(err error) Kind {
+	var fetchErr *Error
+	if errors.As(err, &fetchErr) {
+		return fetchErr.Kind
+	}
+	return Transient
+}
+
+// UserMessage returns a message that is safe to show to users.
+func UserMessage(err error) string {
+	var fetchErr *Error
+	if errors.As(err, &fetchErr) && fetchErr.Message != "" {
+		return fetchErr.Message
+	}
+	return err.Error()
+}
@@ -1,9 +1,10 @@
 package files
 
 import (
-	"bytes"
-	"io"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 
 	"github.com/pocketbase/pocketbase/core"
 	"github.com/pocketbase/pocketbase/tools/filesystem"
@@ -34,48 +35,46 @@ func (c *FileClient) Close() error {
 }
 
 func (c *FileClient) GetFileURL(record *core.Record, field string) string {
-	var domain string
-	if os.Getenv("DEV") == "true" {
-		domain = "localhost:8090"
-	} else {
-		domain = "ytrss.xyz"
-	}
+	return FileURL(record, field)
+}
 
+// FileURL is the public URL of a record's file.
+func FileURL(record *core.Record, field string) string {
 	basePath := record.BaseFilesPath()
 	filename := record.GetString(field)
-	return "https://" + domain + "/api/files/" + basePath + "/" + filename
+	return PublicURL("/api/files/" + basePath + "/" + filename)
 }
 
-func (c *FileClient) GetXMLFile() (*bytes.Buffer, error) {
-	r, err := c.fsys.GetReader(c.fileKey)
-	if err != nil {
-		return nil, err
-	}
-	defer r.Close()
-
-	content := new(bytes.Buffer)
-	_, err = io.Copy(content, r)
-	if err != nil {
-		return nil, err
+// PublicURL is the address feeds and players reach a path of this server at.
+func PublicURL(path string) string {
+	var domain string
+	if os.Getenv("DEV") == "true" {
+		domain = "localhost:8090"
+	} else {
+		domain = "ytrss.xyz"
 	}
 
-	return content, nil
+	return "https://" + domain + path
 }
 
 func (c *FileClient) SetXMLFile(xml string) error {
 	return c.fsys.Upload([]byte(xml), c.fileKey)
 }
 
-func (c *FileClient) NewXMLFile(xml, fileName string) (*filesystem.File, error) {
-	file, err := filesystem.NewFileFromBytes([]byte(xml), fileName+".rss")
-	if err != nil {
-		return nil, err
-	}
+var unsafeNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
 
-	currentFile, err := c.fsys.GetReuploadableFile(c.fileKey, true)
-	if err == nil {
-		file.Name = currentFile.Name
+// ServedName is the file name a download or upload is saved as, built from
+// its title and the stored file's extension.
+func ServedName(record *core.Record) string {
+	title := strings.ReplaceAll(record.GetString("title"), " ", "_")
+	title = unsafeNameChars.ReplaceAllString(title, "")
+	if len(title) > 200 {
+		title = title[:200]
 	}
 
-	return file, nil
+	ext := filepath.Ext(record.GetString("file"))
+	if ext == "" {
+		ext = ".mp3"
+	}
+	return title + ext
 }
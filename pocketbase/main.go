@@ -20,6 +20,7 @@ import (
 	"github.com/lsherman98/yt-rss/pocketbase/pb_hooks/stripe_hooks"
 	"github.com/lsherman98/yt-rss/pocketbase/pb_hooks/uploads_hooks"
 	"github.com/lsherman98/yt-rss/pocketbase/pb_hooks/users_hooks"
+	"github.com/lsherman98/yt-rss/pocketbase/rss_utils"
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/apis"
 	"github.com/pocketbase/pocketbase/core"
@@ -97,6 +98,7 @@ func main() {
 	migratecmd.MustRegister(app, app.RootCmd, migratecmd.Config{
 		Automigrate: isGoRun,
 	})
+	app.RootCmd.AddCommand(rss_utils.NewRebuildFeedsCommand(app))
 
 	if err := app.Start(); err != nil {
 		log.Fatal(err)
@@ -0,0 +1,65 @@
+package migrations
+
+import (
+	"os"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// legacyProxies maps the proxy env vars used before the proxies collection
+// existed to the labels they are imported under.
+var legacyProxies = []struct {
+	label  string
+	key    string
+	envVar string
+}{
+	{"ngrok", "ngrok", "NGROK_PROXY"},
+	{"oxylabs", "oxylabs", "OXY_LABS_PROXY_URL"},
+	{"iproyal", "iproyal", "IP_ROYAL_PROXY_URL"},
+	{"thordata", "thordata", "THORDATA_PROXY_URL"},
+	{"decodo", "decodo", "DECODO_PROXY_URL"},
+	{"evomi 1", "evomi", "EVOMI_PROXY_URL_ONE"},
+	{"evomi 2", "", "EVOMI_PROXY_URL_TWO"},
+	{"evomi 3", "", "EVOMI_PROXY_URL_THREE"},
+	{"evomi 4", "", "EVOMI_PROXY_URL_FOUR"},
+	{"evomi 5", "", "EVOMI_PROXY_URL_FIVE"},
+	{"evomi 6", "", "EVOMI_PROXY_URL_SIX"},
+	{"evomi 7", "", "EVOMI_PROXY_URL_SEVEN"},
+	{"evomi 8", "", "EVOMI_PROXY_URL_EIGHT"},
+}
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("proxies")
+		if err != nil {
+			return err
+		}
+
+		primary := os.Getenv("PROXY")
+		for _, p := range legacyProxies {
+			url := os.Getenv(p.envVar)
+			if url == "" {
+				continue
+			}
+
+			weight := 1
+			if p.key != "" && p.key == primary {
+				weight = 2
+			}
+
+			record := core.NewRecord(collection)
+			record.Set("label", p.label)
+			record.Set("url", url)
+			record.Set("weight", weight)
+			record.Set("enabled", true)
+			if err := app.Save(record); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, func(app core.App) error {
+		return nil
+	})
+}
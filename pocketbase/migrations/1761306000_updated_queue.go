@@ -0,0 +1,84 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_3032203656")
+		if err != nil {
+			return err
+		}
+
+		// update collection data
+		if err := json.Unmarshal([]byte(`{
+			"indexes": [
+				"CREATE UNIQUE INDEX ` + "`" + `idx_m8ZP0lBzAI` + "`" + ` ON ` + "`" + `queue` + "`" + ` (\n  ` + "`" + `worker_id` + "`" + `,\n  ` + "`" + `record_id` + "`" + `\n)",
+				"CREATE INDEX ` + "`" + `idx_queue_status_user` + "`" + ` ON ` + "`" + `queue` + "`" + ` (\n  ` + "`" + `status` + "`" + `,\n  ` + "`" + `user` + "`" + `\n)"
+			]
+		}`), &collection); err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(13, []byte(`{
+			"cascadeDelete": true,
+			"collectionId": "_pb_users_auth_",
+			"hidden": false,
+			"id": "relation2375276105",
+			"maxSelect": 1,
+			"minSelect": 0,
+			"name": "user",
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "relation"
+		}`)); err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(14, []byte(`{
+			"hidden": false,
+			"id": "number1655102503",
+			"max": null,
+			"min": null,
+			"name": "priority",
+			"onlyInt": false,
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "number"
+		}`)); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_3032203656")
+		if err != nil {
+			return err
+		}
+
+		// update collection data
+		if err := json.Unmarshal([]byte(`{
+			"indexes": [
+				"CREATE UNIQUE INDEX ` + "`" + `idx_m8ZP0lBzAI` + "`" + ` ON ` + "`" + `queue` + "`" + ` (\n  ` + "`" + `worker_id` + "`" + `,\n  ` + "`" + `record_id` + "`" + `\n)"
+			]
+		}`), &collection); err != nil {
+			return err
+		}
+
+		// remove field
+		collection.Fields.RemoveById("number1655102503")
+
+		// remove field
+		collection.Fields.RemoveById("relation2375276105")
+
+		return app.Save(collection)
+	})
+}
@@ -0,0 +1,64 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Merges downloads that share a video_id so that the unique index added by the
+// next migration can be created. The record with a file is kept (the oldest if
+// several have one), and jobs and items are pointed at it before the duplicates
+// are deleted.
+func init() {
+	m.Register(func(app core.App) error {
+		rows := []struct {
+			VideoID string `db:"video_id"`
+		}{}
+		err := app.DB().NewQuery(`
+			SELECT video_id FROM downloads
+			WHERE video_id != ''
+			GROUP BY video_id
+			HAVING COUNT(*) > 1
+		`).All(&rows)
+		if err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			downloads, err := app.FindRecordsByFilter("downloads", "video_id = {:videoId}", "+created", 0, 0, dbx.Params{"videoId": row.VideoID})
+			if err != nil {
+				return err
+			}
+
+			keep := downloads[0]
+			for _, d := range downloads {
+				if d.GetString("file") != "" {
+					keep = d
+					break
+				}
+			}
+
+			for _, d := range downloads {
+				if d.Id == keep.Id {
+					continue
+				}
+
+				for _, table := range []string{"jobs", "items"} {
+					_, err := app.DB().Update(table, dbx.Params{"download": keep.Id}, dbx.HashExp{"download": d.Id}).Execute()
+					if err != nil {
+						return err
+					}
+				}
+
+				if err := app.Delete(d); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}, func(app core.App) error {
+		return nil
+	})
+}
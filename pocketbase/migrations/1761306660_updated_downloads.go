@@ -0,0 +1,85 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_2488717294")
+		if err != nil {
+			return err
+		}
+
+		// update collection data
+		if err := json.Unmarshal([]byte(`{
+			"indexes": [
+				"CREATE UNIQUE INDEX ` + "`" + `idx_AO35V4qr0y` + "`" + ` ON ` + "`" + `downloads` + "`" + ` (\n  ` + "`" + `video_id` + "`" + `,\n  ` + "`" + `profile` + "`" + `\n)"
+			]
+		}`), &collection); err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(8, []byte(`{
+			"autogeneratePattern": "",
+			"hidden": false,
+			"id": "text2170006031",
+			"max": 0,
+			"min": 0,
+			"name": "profile",
+			"pattern": "",
+			"presentable": false,
+			"primaryKey": false,
+			"required": false,
+			"system": false,
+			"type": "text"
+		}`)); err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(9, []byte(`{
+			"cascadeDelete": false,
+			"collectionId": "pbc_3032203656",
+			"hidden": false,
+			"id": "relation2776964962",
+			"maxSelect": 1,
+			"minSelect": 0,
+			"name": "fetching_queue",
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "relation"
+		}`)); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_2488717294")
+		if err != nil {
+			return err
+		}
+
+		// update collection data
+		if err := json.Unmarshal([]byte(`{
+			"indexes": [
+				"CREATE INDEX ` + "`" + `idx_AO35V4qr0y` + "`" + ` ON ` + "`" + `downloads` + "`" + ` (` + "`" + `video_id` + "`" + `)"
+			]
+		}`), &collection); err != nil {
+			return err
+		}
+
+		// remove field
+		collection.Fields.RemoveById("relation2776964962")
+
+		// remove field
+		collection.Fields.RemoveById("text2170006031")
+
+		return app.Save(collection)
+	})
+}
@@ -0,0 +1,21 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Jobs handed off to Oxylabs no longer keep the worker that started them, the
+// callback or the status poller claims them instead.
+func init() {
+	m.Register(func(app core.App) error {
+		_, err := app.DB().NewQuery(`
+			UPDATE queue
+			SET worker_id = '', lease_expires_at = ''
+			WHERE status = 'PROCESSING' AND oxylab_job_id != ''
+		`).Execute()
+		return err
+	}, func(app core.App) error {
+		return nil
+	})
+}
@@ -0,0 +1,21 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Downloads made before audio profiles have an empty profile. yt-dlp always
+// produced 192k MP3s and Oxylabs m4a files, so the profile follows the file.
+func init() {
+	m.Register(func(app core.App) error {
+		_, err := app.DB().NewQuery(`
+			UPDATE downloads
+			SET profile = CASE WHEN file LIKE '%.m4a' THEN 'aac-m4a' ELSE 'music-192k-mp3' END
+			WHERE profile = ''
+		`).Execute()
+		return err
+	}, func(app core.App) error {
+		return nil
+	})
+}
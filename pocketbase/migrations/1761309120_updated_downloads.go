@@ -0,0 +1,65 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_2488717294")
+		if err != nil {
+			return err
+		}
+
+		// update collection data
+		if err := json.Unmarshal([]byte(`{
+			"indexes": [
+				"CREATE UNIQUE INDEX ` + "`" + `idx_AO35V4qr0y` + "`" + ` ON ` + "`" + `downloads` + "`" + ` (\n  ` + "`" + `video_id` + "`" + `,\n  ` + "`" + `profile` + "`" + `,\n  ` + "`" + `processing` + "`" + `\n)"
+			]
+		}`), &collection); err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(10, []byte(`{
+			"autogeneratePattern": "",
+			"hidden": false,
+			"id": "text2288823083",
+			"max": 0,
+			"min": 0,
+			"name": "processing",
+			"pattern": "",
+			"presentable": false,
+			"primaryKey": false,
+			"required": false,
+			"system": false,
+			"type": "text"
+		}`)); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_2488717294")
+		if err != nil {
+			return err
+		}
+
+		// update collection data
+		if err := json.Unmarshal([]byte(`{
+			"indexes": [
+				"CREATE UNIQUE INDEX ` + "`" + `idx_AO35V4qr0y` + "`" + ` ON ` + "`" + `downloads` + "`" + ` (\n  ` + "`" + `video_id` + "`" + `,\n  ` + "`" + `profile` + "`" + `\n)"
+			]
+		}`), &collection); err != nil {
+			return err
+		}
+
+		// remove field
+		collection.Fields.RemoveById("text2288823083")
+
+		return app.Save(collection)
+	})
+}
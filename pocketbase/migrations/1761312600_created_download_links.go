@@ -0,0 +1,141 @@
+package migrations
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		jsonData := `{
+			"createRule": null,
+			"deleteRule": null,
+			"fields": [
+				{
+					"autogeneratePattern": "[a-z0-9]{15}",
+					"hidden": false,
+					"id": "text3208210256",
+					"max": 15,
+					"min": 15,
+					"name": "id",
+					"pattern": "^[a-z0-9]+$",
+					"presentable": false,
+					"primaryKey": true,
+					"required": true,
+					"system": true,
+					"type": "text"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text2988741373",
+					"max": 0,
+					"min": 0,
+					"name": "nonce",
+					"pattern": "",
+					"presentable": false,
+					"primaryKey": false,
+					"required": true,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"cascadeDelete": true,
+					"collectionId": "pbc_2409499253",
+					"hidden": false,
+					"id": "relation4225294584",
+					"maxSelect": 1,
+					"minSelect": 0,
+					"name": "job",
+					"presentable": false,
+					"required": true,
+					"system": false,
+					"type": "relation"
+				},
+				{
+					"hidden": false,
+					"id": "date261981154",
+					"max": "",
+					"min": "",
+					"name": "expires_at",
+					"presentable": false,
+					"required": true,
+					"system": false,
+					"type": "date"
+				},
+				{
+					"autogeneratePattern": "",
+					"hidden": false,
+					"id": "text53591388",
+					"max": 0,
+					"min": 0,
+					"name": "client_ip",
+					"pattern": "",
+					"presentable": false,
+					"primaryKey": false,
+					"required": false,
+					"system": false,
+					"type": "text"
+				},
+				{
+					"hidden": false,
+					"id": "date124928442",
+					"max": "",
+					"min": "",
+					"name": "used_at",
+					"presentable": false,
+					"required": false,
+					"system": false,
+					"type": "date"
+				},
+				{
+					"hidden": false,
+					"id": "autodate2990389176",
+					"name": "created",
+					"onCreate": true,
+					"onUpdate": false,
+					"presentable": false,
+					"system": false,
+					"type": "autodate"
+				},
+				{
+					"hidden": false,
+					"id": "autodate3332085495",
+					"name": "updated",
+					"onCreate": true,
+					"onUpdate": true,
+					"presentable": false,
+					"system": false,
+					"type": "autodate"
+				}
+			],
+			"id": "pbc_4277677476",
+			"indexes": [
+				"CREATE UNIQUE INDEX ` + "`" + `idx_Wq3n8KdT1x` + "`" + ` ON ` + "`" + `download_links` + "`" + ` (` + "`" + `nonce` + "`" + `)",
+				"CREATE INDEX ` + "`" + `idx_Lr7cX2mPa9` + "`" + ` ON ` + "`" + `download_links` + "`" + ` (` + "`" + `expires_at` + "`" + `)"
+			],
+			"listRule": null,
+			"name": "download_links",
+			"system": false,
+			"type": "base",
+			"updateRule": null,
+			"viewRule": null
+		}`
+
+		collection := &core.Collection{}
+		if err := json.Unmarshal([]byte(jsonData), &collection); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_4277677476")
+		if err != nil {
+			return err
+		}
+
+		return app.Delete(collection)
+	})
+}
@@ -0,0 +1,21 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Feeds are rendered in publishing order. Items finished before it was
+// recorded were added to their feed when they last changed.
+func init() {
+	m.Register(func(app core.App) error {
+		_, err := app.DB().NewQuery(`
+			UPDATE items
+			SET published_at = updated
+			WHERE status = 'SUCCESS' AND published_at = ''
+		`).Execute()
+		return err
+	}, func(app core.App) error {
+		return nil
+	})
+}
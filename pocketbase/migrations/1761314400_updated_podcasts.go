@@ -0,0 +1,223 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_3271294384")
+		if err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(19, []byte(`{
+			"hidden": false,
+			"id": "json989021800",
+			"maxSize": 0,
+			"name": "categories",
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "json"
+		}`)); err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(20, []byte(`{
+			"hidden": false,
+			"id": "bool2594623564",
+			"name": "explicit",
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "bool"
+		}`)); err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(21, []byte(`{
+			"autogeneratePattern": "",
+			"hidden": false,
+			"id": "text3571151285",
+			"max": 35,
+			"min": 0,
+			"name": "language",
+			"pattern": "",
+			"presentable": false,
+			"primaryKey": false,
+			"required": false,
+			"system": false,
+			"type": "text"
+		}`)); err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(22, []byte(`{
+			"autogeneratePattern": "",
+			"hidden": false,
+			"id": "text947983859",
+			"max": 255,
+			"min": 0,
+			"name": "copyright",
+			"pattern": "",
+			"presentable": false,
+			"primaryKey": false,
+			"required": false,
+			"system": false,
+			"type": "text"
+		}`)); err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(23, []byte(`{
+			"hidden": false,
+			"id": "select2198389922",
+			"maxSelect": 1,
+			"name": "itunes_type",
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "select",
+			"values": [
+				"episodic",
+				"serial"
+			]
+		}`)); err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(24, []byte(`{
+			"hidden": false,
+			"id": "bool4185791257",
+			"name": "itunes_block",
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "bool"
+		}`)); err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(25, []byte(`{
+			"hidden": false,
+			"id": "bool3233257656",
+			"name": "itunes_complete",
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "bool"
+		}`)); err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(26, []byte(`{
+			"autogeneratePattern": "",
+			"hidden": false,
+			"id": "text3226161662",
+			"max": 36,
+			"min": 0,
+			"name": "podcast_guid",
+			"pattern": "",
+			"presentable": false,
+			"primaryKey": false,
+			"required": false,
+			"system": false,
+			"type": "text"
+		}`)); err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(27, []byte(`{
+			"hidden": false,
+			"id": "bool3939682449",
+			"name": "locked",
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "bool"
+		}`)); err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(28, []byte(`{
+			"hidden": false,
+			"id": "json3540898262",
+			"maxSize": 0,
+			"name": "funding",
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "json"
+		}`)); err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(29, []byte(`{
+			"hidden": false,
+			"id": "json2723989459",
+			"maxSize": 0,
+			"name": "persons",
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "json"
+		}`)); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_3271294384")
+		if err != nil {
+			return err
+		}
+
+		// remove field
+		collection.Fields.RemoveById("json2723989459")
+
+		// remove field
+		collection.Fields.RemoveById("json3540898262")
+
+		// remove field
+		collection.Fields.RemoveById("bool3939682449")
+
+		// remove field
+		collection.Fields.RemoveById("text3226161662")
+
+		// remove field
+		collection.Fields.RemoveById("bool3233257656")
+
+		// remove field
+		collection.Fields.RemoveById("bool4185791257")
+
+		// remove field
+		collection.Fields.RemoveById("select2198389922")
+
+		// remove field
+		collection.Fields.RemoveById("text947983859")
+
+		// remove field
+		collection.Fields.RemoveById("text3571151285")
+
+		// remove field
+		collection.Fields.RemoveById("bool2594623564")
+
+		// remove field
+		collection.Fields.RemoveById("json989021800")
+
+		return app.Save(collection)
+	})
+}
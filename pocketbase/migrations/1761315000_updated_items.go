@@ -0,0 +1,139 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_4204686209")
+		if err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(12, []byte(`{
+			"autogeneratePattern": "",
+			"hidden": false,
+			"id": "text3896213506",
+			"max": 255,
+			"min": 0,
+			"name": "custom_title",
+			"pattern": "",
+			"presentable": false,
+			"primaryKey": false,
+			"required": false,
+			"system": false,
+			"type": "text"
+		}`)); err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(13, []byte(`{
+			"convertURLs": false,
+			"hidden": false,
+			"id": "editor61522512",
+			"maxSize": 0,
+			"name": "show_notes",
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "editor"
+		}`)); err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(14, []byte(`{
+			"hidden": false,
+			"id": "number4041497513",
+			"max": null,
+			"min": 0,
+			"name": "season",
+			"onlyInt": true,
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "number"
+		}`)); err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(15, []byte(`{
+			"hidden": false,
+			"id": "number3718913242",
+			"max": null,
+			"min": 0,
+			"name": "episode",
+			"onlyInt": true,
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "number"
+		}`)); err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(16, []byte(`{
+			"hidden": false,
+			"id": "select3892890377",
+			"maxSelect": 1,
+			"name": "episode_type",
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "select",
+			"values": [
+				"full",
+				"trailer",
+				"bonus"
+			]
+		}`)); err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(17, []byte(`{
+			"hidden": false,
+			"id": "bool2594623564",
+			"name": "explicit",
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "bool"
+		}`)); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_4204686209")
+		if err != nil {
+			return err
+		}
+
+		// remove field
+		collection.Fields.RemoveById("bool2594623564")
+
+		// remove field
+		collection.Fields.RemoveById("select3892890377")
+
+		// remove field
+		collection.Fields.RemoveById("number3718913242")
+
+		// remove field
+		collection.Fields.RemoveById("number4041497513")
+
+		// remove field
+		collection.Fields.RemoveById("editor61522512")
+
+		// remove field
+		collection.Fields.RemoveById("text3896213506")
+
+		return app.Save(collection)
+	})
+}
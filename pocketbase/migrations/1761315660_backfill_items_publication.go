@@ -0,0 +1,20 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Items added before drafts and scheduling were published when they finished.
+func init() {
+	m.Register(func(app core.App) error {
+		_, err := app.DB().NewQuery(`
+			UPDATE items
+			SET publication = 'published'
+			WHERE publication = ''
+		`).Execute()
+		return err
+	}, func(app core.App) error {
+		return nil
+	})
+}
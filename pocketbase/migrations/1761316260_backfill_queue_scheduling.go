@@ -0,0 +1,45 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Queue records added before fair scheduling have no user or priority, so the
+// scheduler would treat them as one anonymous user at the lowest weight. The
+// priority uses the default tier weights.
+func init() {
+	m.Register(func(app core.App) error {
+		_, err := app.DB().NewQuery(`
+			UPDATE queue
+			SET user = COALESCE(
+				(SELECT jobs.user FROM jobs WHERE jobs.id = queue.record_id AND queue.collection = 'jobs'),
+				(SELECT items.user FROM items WHERE items.id = queue.record_id AND queue.collection = 'items'),
+				''
+			)
+			WHERE user = ''
+		`).Execute()
+		if err != nil {
+			return err
+		}
+
+		_, err = app.DB().NewQuery(`
+			UPDATE queue
+			SET priority = COALESCE((
+				SELECT CASE
+					WHEN subscription_tiers.lookup_key LIKE 'professional%' THEN 8
+					WHEN subscription_tiers.lookup_key LIKE 'power_user%' THEN 4
+					WHEN subscription_tiers.lookup_key LIKE 'basic%' THEN 2
+					ELSE 1
+				END
+				FROM users
+				JOIN subscription_tiers ON subscription_tiers.id = users.tier
+				WHERE users.id = queue.user
+			), 1) * CASE WHEN queue.collection = 'items' THEN 2 ELSE 1 END
+			WHERE priority = 0
+		`).Execute()
+		return err
+	}, func(app core.App) error {
+		return nil
+	})
+}
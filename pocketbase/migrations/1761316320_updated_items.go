@@ -0,0 +1,69 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_4204686209")
+		if err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(20, []byte(`{
+			"hidden": false,
+			"id": "file2359244304",
+			"maxSelect": 1,
+			"maxSize": 100000000000,
+			"mimeTypes": [
+				"audio/mpeg",
+				"audio/x-m4a",
+				"audio/ogg",
+				"video/webm"
+			],
+			"name": "file",
+			"presentable": false,
+			"protected": false,
+			"required": false,
+			"system": false,
+			"thumbs": [],
+			"type": "file"
+		}`)); err != nil {
+			return err
+		}
+
+		// add field
+		if err := collection.Fields.AddMarshaledJSONAt(21, []byte(`{
+			"hidden": false,
+			"id": "number4156564586",
+			"max": null,
+			"min": null,
+			"name": "size",
+			"onlyInt": false,
+			"presentable": false,
+			"required": false,
+			"system": false,
+			"type": "number"
+		}`)); err != nil {
+			return err
+		}
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("pbc_4204686209")
+		if err != nil {
+			return err
+		}
+
+		// remove field
+		collection.Fields.RemoveById("number4156564586")
+
+		// remove field
+		collection.Fields.RemoveById("file2359244304")
+
+		return app.Save(collection)
+	})
+}
@@ -7,11 +7,13 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"time"
 
 	"cloud.google.com/go/storage"
+	"github.com/lsherman98/yt-rss/pocketbase/fetch_errors"
 )
 
 const (
@@ -82,7 +84,10 @@ type JobCreateResponse struct {
 	ID string `json:"id"`
 }
 
-func (c *Client) Start(videoID string, jobId string) (*JobCreateResponse, error) {
+// Start creates a download job. Oxylabs calls back to the webhook for jobId
+// with secret in the query string, which the webhook checks before trusting
+// the callback.
+func (c *Client) Start(ctx context.Context, videoID string, jobId string, secret string) (*JobCreateResponse, error) {
 	payload := JobPayload{
 		Source: source,
 		Query:  videoID,
@@ -91,7 +96,7 @@ func (c *Client) Start(videoID string, jobId string) (*JobCreateResponse, error)
 		},
 		StorageType: storageType,
 		StorageURL:  c.storageBucket,
-		CallbackURL: c.callbackURL + "/" + jobId,
+		CallbackURL: c.callbackURL + "/" + jobId + "?secret=" + url.QueryEscape(secret),
 	}
 
 	jsonValue, err := json.Marshal(payload)
@@ -99,7 +104,7 @@ func (c *Client) Start(videoID string, jobId string) (*JobCreateResponse, error)
 		return nil, fmt.Errorf("failed to marshal payload: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonValue))
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(jsonValue))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -109,13 +114,13 @@ func (c *Client) Start(videoID string, jobId string) (*JobCreateResponse, error)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, fetch_errors.New(fetch_errors.Transient, "", fmt.Errorf("failed to send request: %w", err))
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusAccepted {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, classifyStatus(resp.StatusCode, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body)))
 	}
 
 	var jobResp JobCreateResponse
@@ -126,9 +131,45 @@ func (c *Client) Start(videoID string, jobId string) (*JobCreateResponse, error)
 	return &jobResp, nil
 }
 
-func (c *Client) DownloadFile(videoID, jobID string) (string, error) {
-	ctx := context.Background()
+type JobStatusResponse struct {
+	ID        string `json:"id"`
+	Query     string `json:"query"`
+	Status    string `json:"status"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// JobStatus looks up a job directly, for when its callback never arrived.
+func (c *Client) JobStatus(ctx context.Context, jobID string) (*JobStatusResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL+"/"+jobID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.SetBasicAuth(c.username, c.password)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fetch_errors.New(fetch_errors.Transient, "", fmt.Errorf("failed to send request: %w", err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, classifyStatus(resp.StatusCode, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body)))
+	}
 
+	var statusResp JobStatusResponse
+	if err := json.NewDecoder(resp.Body).Decode(&statusResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &statusResp, nil
+}
+
+// DownloadFile streams a job's file from the bucket to pb_data/output and
+// returns its path. Cancelling ctx stops the download.
+func (c *Client) DownloadFile(ctx context.Context, videoID, jobID string) (string, error) {
 	objectName := fmt.Sprintf("%s_%s.m4a", videoID, jobID)
 
 	var rc *storage.Reader
@@ -139,7 +180,11 @@ func (c *Client) DownloadFile(videoID, jobID string) (string, error) {
 			break
 		}
 		if attempt < 2 {
-			time.Sleep(5 * time.Second * time.Duration(attempt))
+			select {
+			case <-ctx.Done():
+				return "", ctx.Err()
+			case <-time.After(5 * time.Second * time.Duration(attempt)):
+			}
 		}
 	}
 	if err != nil {
@@ -147,33 +192,40 @@ func (c *Client) DownloadFile(videoID, jobID string) (string, error) {
 	}
 	defer rc.Close()
 
-	data, err := io.ReadAll(rc)
+	destPath := filepath.Join("pb_data", "output", fmt.Sprintf("%s_%s.m4a", videoID, jobID))
+	f, err := os.Create(destPath)
 	if err != nil {
-		return "", fmt.Errorf("ioutil.ReadAll: %w", err)
+		return "", fmt.Errorf("os.Create: %w", err)
 	}
+	defer f.Close()
 
-	destPath := filepath.Join("pb_data", "output", fmt.Sprintf("%s_%s.m4a", videoID, jobID))
-	if err := os.WriteFile(destPath, data, 0644); err != nil {
-		return "", fmt.Errorf("os.WriteFile: %w", err)
+	// the object is streamed to disk, episodes can be hundreds of megabytes
+	if _, err := io.Copy(f, rc); err != nil {
+		os.Remove(destPath)
+		return "", fmt.Errorf("io.Copy: %w", err)
 	}
 
-	// deleteCtx, cancel := context.WithTimeout(ctx, time.Second*10)
-	// defer cancel()
+	return destPath, nil
+}
 
-	// o := c.gcpClient.Bucket(c.storageBucket).Object(objectName)
+// DeleteFile removes a job's file from the bucket once it has been stored.
+func (c *Client) DeleteFile(videoID, jobID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
 
-	// Optional: set a generation-match precondition to avoid potential race
-	// conditions and data corruptions. The request to delete the file is aborted
-	// if the object's generation number does not match your precondition.
-	// attrs, err := o.Attrs(deleteCtx)
-	// if err != nil {
-	// 	return "", fmt.Errorf("object.Attrs: %w", err)
-	// }
-	// o = o.If(storage.Conditions{GenerationMatch: attrs.Generation})
+	objectName := fmt.Sprintf("%s_%s.m4a", videoID, jobID)
+	o := c.gcpClient.Bucket(c.storageBucket).Object(objectName)
 
-	// if err := o.Delete(deleteCtx); err != nil {
-	// 	return "", fmt.Errorf("Object(%q).Delete: %w", objectName, err)
-	// }
+	// the generation precondition keeps a rewritten object from being deleted
+	attrs, err := o.Attrs(ctx)
+	if err != nil {
+		return fmt.Errorf("object.Attrs: %w", err)
+	}
+	o = o.If(storage.Conditions{GenerationMatch: attrs.Generation})
 
-	return destPath, nil
+	if err := o.Delete(ctx); err != nil {
+		return fmt.Errorf("Object(%q).Delete: %w", objectName, err)
+	}
+
+	return nil
 }
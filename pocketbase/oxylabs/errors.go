@@ -0,0 +1,21 @@
+package oxylabs
+
+import (
+	"net/http"
+
+	"github.com/lsherman98/yt-rss/pocketbase/fetch_errors"
+)
+
+// classifyStatus maps an Oxylabs API status code to a fetch_errors kind. A
+// rejected request says nothing about the video itself, often it's only our
+// payload or the source, so it is transient and the next fetcher gets a try.
+func classifyStatus(status int, err error) error {
+	switch {
+	case status == http.StatusTooManyRequests:
+		return fetch_errors.New(fetch_errors.RateLimited, "The download service is busy, please try again later.", err)
+	case status == http.StatusBadRequest, status == http.StatusUnprocessableEntity:
+		return fetch_errors.New(fetch_errors.Transient, "This video could not be downloaded.", err)
+	default:
+		return fetch_errors.New(fetch_errors.Transient, "", err)
+	}
+}
@@ -0,0 +1,61 @@
+package api_hooks
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/lsherman98/yt-rss/pocketbase/collections"
+	"github.com/lsherman98/yt-rss/pocketbase/downloader"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func cancelJobHandler(e *core.RequestEvent) error {
+	job, err := cancelRecord(e, collections.Jobs, e.Request.PathValue("jobId"))
+	if err != nil {
+		return err
+	}
+
+	return e.JSON(http.StatusOK, JobResponse{
+		ID:     job.Id,
+		URL:    job.GetString("url"),
+		Status: job.GetString("status"),
+	})
+}
+
+func cancelItemHandler(e *core.RequestEvent) error {
+	item, err := cancelRecord(e, collections.Items, e.Request.PathValue("itemId"))
+	if err != nil {
+		return err
+	}
+
+	return e.JSON(http.StatusOK, ItemResponse{
+		Status: item.GetString("status"),
+		Title:  item.GetString("title"),
+	})
+}
+
+// cancelRecord is shared by the API key routes and the routes used by the UI,
+// so the owner is taken from whichever of the two authenticated the request.
+func cancelRecord(e *core.RequestEvent, collection string, id string) (*core.Record, error) {
+	userId := ""
+	if e.Auth != nil {
+		userId = e.Auth.Id
+	} else if user, ok := e.Get("user").(*core.Record); ok {
+		userId = user.Id
+	}
+
+	record, err := e.App.FindRecordById(collection, id)
+	if err != nil || userId == "" || record.GetString("user") != userId {
+		return nil, e.NotFoundError("record not found", nil)
+	}
+
+	if err := downloader.Cancel(e.App, record); err != nil {
+		if errors.Is(err, downloader.ErrNotCancellable) {
+			return nil, e.BadRequestError(err.Error(), nil)
+		}
+		e.App.Logger().Error("API: failed to cancel record", "collection", collection, "record_id", id, "error", err)
+		return nil, e.InternalServerError("failed to cancel", nil)
+	}
+
+	return record, nil
+}
@@ -2,10 +2,14 @@ package api_hooks
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/lsherman98/yt-rss/pocketbase/collections"
+	"github.com/lsherman98/yt-rss/pocketbase/downloader"
+	"github.com/lsherman98/yt-rss/pocketbase/rss_utils"
 	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
 )
 
 func addItemHandler(e *core.RequestEvent) error {
@@ -27,6 +31,23 @@ func addItemHandler(e *core.RequestEvent) error {
 	item.Set("url", body.URL)
 	item.Set("type", "url")
 	item.Set("status", "CREATED")
+
+	if body.PublishAt != "" {
+		publishAt, err := types.ParseDateTime(body.PublishAt)
+		if err != nil || publishAt.IsZero() {
+			return e.BadRequestError("invalid publish_at", nil)
+		}
+
+		if publishAt.Time().After(time.Now()) {
+			if err := rss_utils.SetPublication(item, rss_utils.Scheduled, publishAt); err != nil {
+				return e.BadRequestError(err.Error(), nil)
+			}
+		} else {
+			item.Set("publication", rss_utils.Published)
+			item.Set("published_at", publishAt)
+		}
+	}
+
 	if err := e.App.Save(item); err != nil {
 		return e.InternalServerError("internal server error", nil)
 	}
@@ -47,13 +68,21 @@ func getItemsHandler(e *core.RequestEvent) error {
 		return e.NotFoundError("No items found", nil)
 	}
 
+	positions, err := downloader.QueuePositions(e.App)
+	if err != nil {
+		e.App.Logger().Error("API: failed to estimate queue positions", "error", err)
+	}
+
 	ItemResponses := []ItemResponse{}
 	for _, item := range items {
 		response := ItemResponse{
-			Status:  item.GetString("status"),
-			Title:   item.GetString("title"),
-			Error:   item.GetString("error"),
-			Created: item.GetString("created"),
+			Status:        item.GetString("status"),
+			Title:         item.GetString("title"),
+			Error:         item.GetString("error"),
+			Created:       item.GetString("created"),
+			QueuePosition: positions[item.Id],
+			Publication:   item.GetString("publication"),
+			PublishAt:     item.GetString("publish_at"),
 		}
 		ItemResponses = append(ItemResponses, response)
 	}
@@ -0,0 +1,72 @@
+package api_hooks
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/lsherman98/yt-rss/pocketbase/audio_profiles"
+	"github.com/lsherman98/yt-rss/pocketbase/chapters"
+	"github.com/lsherman98/yt-rss/pocketbase/collections"
+	"github.com/lsherman98/yt-rss/pocketbase/sponsorblock"
+	"github.com/lsherman98/yt-rss/pocketbase/transcripts"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// chaptersHandler serves the Podcasting 2.0 chapters file of a download. It is
+// public like the feeds and audio files that link to it.
+func chaptersHandler(e *core.RequestEvent) error {
+	download, err := e.App.FindRecordById(collections.Downloads, e.Request.PathValue("downloadId"))
+	if err != nil {
+		return e.NotFoundError("download not found", nil)
+	}
+
+	list := []chapters.Chapter{}
+	if err := download.UnmarshalJSONField("chapters", &list); err != nil || len(list) == 0 {
+		return e.NotFoundError("download has no chapters", nil)
+	}
+
+	body, err := chapters.FeedJSON(list)
+	if err != nil {
+		return e.InternalServerError("failed to encode chapters", err)
+	}
+
+	return e.Blob(http.StatusOK, chapters.MimeType, body)
+}
+
+// transcriptHandler serves the transcript of a download, with its cues moved
+// onto the timeline of the audio file.
+func transcriptHandler(e *core.RequestEvent) error {
+	download, err := e.App.FindRecordById(collections.Downloads, e.Request.PathValue("downloadId"))
+	if err != nil {
+		return e.NotFoundError("download not found", nil)
+	}
+
+	fileName := download.GetString("transcript")
+	if fileName == "" {
+		return e.NotFoundError("download has no transcript", nil)
+	}
+
+	fsys, err := e.App.NewFilesystem()
+	if err != nil {
+		return e.InternalServerError("internal server error", nil)
+	}
+	defer fsys.Close()
+
+	r, err := fsys.GetReader(download.BaseFilesPath() + "/" + fileName)
+	if err != nil {
+		return e.InternalServerError("internal server error", nil)
+	}
+	defer r.Close()
+
+	content := new(bytes.Buffer)
+	if _, err := io.Copy(content, r); err != nil {
+		return e.InternalServerError("internal server error", nil)
+	}
+
+	cuts := []sponsorblock.Segment{}
+	download.UnmarshalJSONField("segments", &cuts)
+	speed := audio_profiles.ParseProcessing(download.GetString("processing")).Speed
+
+	return e.Blob(http.StatusOK, transcripts.MimeType(fileName), transcripts.Retime(content.Bytes(), cuts, speed))
+}
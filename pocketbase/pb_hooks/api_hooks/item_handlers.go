@@ -0,0 +1,135 @@
+package api_hooks
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lsherman98/yt-rss/pocketbase/collections"
+	"github.com/lsherman98/yt-rss/pocketbase/rss_utils"
+	"github.com/lsherman98/yt-rss/pocketbase/show_notes"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+var episodeTypes = []string{"", "full", "trailer", "bonus"}
+
+// updateItemHandler edits the episode metadata of one of the user's items and
+// rebuilds the feed it's in. Items can't be updated through the records API,
+// so that their status and files stay the downloader's.
+func updateItemHandler(e *core.RequestEvent) error {
+	item, err := e.App.FindRecordById(collections.Items, e.Request.PathValue("itemId"))
+	if err != nil || item.GetString("user") != e.Auth.Id {
+		return e.NotFoundError("item not found", nil)
+	}
+
+	var body UpdateItemRequest
+	if err := e.BindBody(&body); err != nil {
+		return e.BadRequestError("invalid request body", err)
+	}
+
+	// the edits are applied to the item as it is now, so that the downloader's
+	// changes since the request started aren't written back
+	err = e.App.RunInTransaction(func(txApp core.App) error {
+		current, err := txApp.FindRecordById(collections.Items, item.Id)
+		if err != nil {
+			return err
+		}
+		item = current
+
+		if body.CustomTitle != nil {
+			title := strings.TrimSpace(*body.CustomTitle)
+			if utf8.RuneCountInString(title) > 255 {
+				return e.BadRequestError("titles can be at most 255 characters", nil)
+			}
+			item.Set("custom_title", title)
+		}
+
+		if body.ShowNotes != nil {
+			notes := show_notes.Sanitize(*body.ShowNotes)
+			if utf8.RuneCountInString(notes) > show_notes.MaxLength {
+				return e.BadRequestError(fmt.Sprintf("show notes can be at most %d characters", show_notes.MaxLength), nil)
+			}
+			item.Set("show_notes", notes)
+		}
+
+		if body.Season != nil {
+			if *body.Season < 0 {
+				return e.BadRequestError("season can't be negative", nil)
+			}
+			item.Set("season", *body.Season)
+		}
+
+		if body.Episode != nil {
+			if *body.Episode < 0 {
+				return e.BadRequestError("episode can't be negative", nil)
+			}
+			item.Set("episode", *body.Episode)
+		}
+
+		if body.EpisodeType != nil {
+			if !slices.Contains(episodeTypes, *body.EpisodeType) {
+				return e.BadRequestError("episode type must be full, trailer or bonus", nil)
+			}
+			item.Set("episode_type", *body.EpisodeType)
+		}
+
+		if body.Explicit != nil {
+			item.Set("explicit", *body.Explicit)
+		}
+
+		if body.Publication != nil {
+			publishAt := item.GetDateTime("publish_at")
+			if body.PublishAt != nil {
+				publishAt, err = types.ParseDateTime(*body.PublishAt)
+				if err != nil {
+					return e.BadRequestError("invalid publish time", err)
+				}
+			}
+			if err := rss_utils.SetPublication(item, *body.Publication, publishAt); err != nil {
+				return e.BadRequestError(err.Error(), nil)
+			}
+		}
+
+		switch {
+		case body.UseUploadDate:
+			download, err := txApp.FindRecordById(collections.Downloads, item.GetString("download"))
+			if err != nil || download.GetDateTime("upload_date").IsZero() {
+				return e.BadRequestError("the item has no YouTube upload date", nil)
+			}
+			item.Set("published_at", download.GetDateTime("upload_date"))
+		case body.PublishedAt != nil:
+			published, err := types.ParseDateTime(*body.PublishedAt)
+			if err != nil || published.IsZero() {
+				return e.BadRequestError("invalid publish date", err)
+			}
+			item.Set("published_at", published)
+		}
+
+		return txApp.Save(item)
+	})
+	var apiErr *router.ApiError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	if err != nil {
+		e.App.Logger().Error("API: failed to update item", "item_id", item.Id, "error", err)
+		return e.InternalServerError("failed to update item", nil)
+	}
+
+	podcastRecord, err := e.App.FindRecordById(collections.Podcasts, item.GetString("podcast"))
+	if err != nil {
+		e.App.Logger().Error("API: failed to find podcast", "item_id", item.Id, "error", err)
+		return e.InternalServerError("failed to rebuild feed", nil)
+	}
+	if err := rss_utils.RebuildFeed(e.App, podcastRecord); err != nil {
+		e.App.Logger().Error("API: failed to rebuild feed", "podcast_id", podcastRecord.Id, "error", err)
+		return e.InternalServerError("failed to rebuild feed", nil)
+	}
+
+	return e.JSON(http.StatusOK, item)
+}
@@ -1,11 +1,14 @@
 package api_hooks
 
 import (
-	"bytes"
-	"io"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"github.com/lsherman98/yt-rss/pocketbase/audio_profiles"
 	"github.com/lsherman98/yt-rss/pocketbase/collections"
+	"github.com/lsherman98/yt-rss/pocketbase/downloader"
+	"github.com/lsherman98/yt-rss/pocketbase/files"
 	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase/core"
 	"github.com/pocketbase/pocketbase/tools/security"
@@ -25,6 +28,26 @@ func convertHandler(e *core.RequestEvent) error {
 		return e.BadRequestError("Invalid number of URLs, must be between 1 and "+string(rune(URLsLimit)), nil)
 	}
 
+	if body.AudioProfile != "" {
+		if _, ok := audio_profiles.Get(body.AudioProfile); !ok {
+			return e.BadRequestError("Invalid audio_profile, must be one of: "+strings.Join(audio_profiles.Names(), ", "), nil)
+		}
+	}
+
+	processing := audio_profiles.Processing{
+		LoudnessTarget: body.LoudnessTarget,
+		TrimSilence:    body.TrimSilence,
+		Speed:          body.Speed,
+	}
+	if err := processing.Validate(); err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	speed := ""
+	if body.Speed != 0 && body.Speed != 1 {
+		speed = strconv.FormatFloat(body.Speed, 'f', -1, 64)
+	}
+
 	user := e.Get("user").(*core.Record)
 	apiKeyRecord := e.Get("apiKeyRecord").(*core.Record)
 
@@ -44,6 +67,10 @@ func convertHandler(e *core.RequestEvent) error {
 			jobRecord.Set("status", "CREATED")
 			jobRecord.Set("batch_id", batchId)
 			jobRecord.Set("api_key", apiKeyRecord.Id)
+			jobRecord.Set("audio_profile", body.AudioProfile)
+			jobRecord.Set("loudness_target", body.LoudnessTarget)
+			jobRecord.Set("trim_silence", body.TrimSilence)
+			jobRecord.Set("speed", speed)
 			if err := txApp.Save(jobRecord); err != nil {
 				return err
 			}
@@ -74,6 +101,11 @@ func pollBatchHandler(e *core.RequestEvent) error {
 		return e.BadRequestError("missing batchId parameter", nil)
 	}
 
+	signer, err := linkSignerFor(e)
+	if err != nil {
+		return err
+	}
+
 	jobCollection, err := e.App.FindCollectionByNameOrId(collections.Jobs)
 	if err != nil {
 		return e.InternalServerError("internal server error", nil)
@@ -84,12 +116,18 @@ func pollBatchHandler(e *core.RequestEvent) error {
 		return e.NotFoundError("batch not found", nil)
 	}
 
+	positions, err := downloader.QueuePositions(e.App)
+	if err != nil {
+		e.App.Logger().Error("API: failed to estimate queue positions", "error", err)
+	}
+
 	jobsResponse := []JobResponse{}
 	batchSize := len(jobs)
 	pendingCount := 0
 	processingCount := 0
 	successCount := 0
 	errorCount := 0
+	cancelledCount := 0
 
 	for _, job := range jobs {
 		url := job.GetString("url")
@@ -112,25 +150,32 @@ func pollBatchHandler(e *core.RequestEvent) error {
 			videoId := download.GetString("video_id")
 			size := download.GetInt("size")
 			downloadEndpoint := "/api/v1/download/" + job.Id
+			signedURL, signedURLExpiresAt := signer.sign(e.App, job)
 
 			jobsResponse = append(jobsResponse, JobResponse{
-				ID:               id,
-				URL:              url,
-				Status:           status,
-				DownloadEndpoint: downloadEndpoint,
+				ID:                 id,
+				URL:                url,
+				Status:             status,
+				DownloadEndpoint:   downloadEndpoint,
+				SignedURL:          signedURL,
+				SignedURLExpiresAt: signedURLExpiresAt,
 				VideoMetadata: &VideoMetadata{
-					Title:       title,
-					Description: description,
-					Duration:    duration,
-					VideoID:     videoId,
-					Size:        size,
+					Title:         title,
+					Description:   description,
+					Duration:      duration,
+					VideoID:       videoId,
+					Size:          size,
+					Profile:       download.GetString("profile"),
+					Processing:    processingMetadata(download),
+					TranscriptURL: transcriptURL(download),
 				},
 			})
 		} else {
 			jobsResponse = append(jobsResponse, JobResponse{
-				ID:     id,
-				URL:    url,
-				Status: status,
+				ID:            id,
+				URL:           url,
+				Status:        status,
+				QueuePosition: positions[id],
 			})
 		}
 
@@ -143,10 +188,12 @@ func pollBatchHandler(e *core.RequestEvent) error {
 			successCount++
 		case "ERROR":
 			errorCount++
+		case "CANCELLED":
+			cancelledCount++
 		}
 	}
 
-	batchComplete := successCount+errorCount == batchSize
+	batchComplete := successCount+errorCount+cancelledCount == batchSize
 
 	return e.JSON(200, map[string]any{
 		"batch_id": batchId,
@@ -157,6 +204,7 @@ func pollBatchHandler(e *core.RequestEvent) error {
 			"processing": processingCount,
 			"success":    successCount,
 			"error":      errorCount,
+			"cancelled":  cancelledCount,
 		},
 	})
 }
@@ -167,6 +215,11 @@ func pollJobHandler(e *core.RequestEvent) error {
 		return e.BadRequestError("Missing jobId parameter", nil)
 	}
 
+	signer, err := linkSignerFor(e)
+	if err != nil {
+		return err
+	}
+
 	job, err := e.App.FindRecordById(collections.Jobs, jobId)
 	if err != nil || job == nil {
 		return e.NotFoundError("Job not found", nil)
@@ -187,25 +240,37 @@ func pollJobHandler(e *core.RequestEvent) error {
 		videoId := download.GetString("video_id")
 		size := download.GetInt("size")
 		downloadEndpoint := "/api/v1/download/" + job.Id
+		signedURL, signedURLExpiresAt := signer.sign(e.App, job)
 
 		return e.JSON(200, JobResponse{
-			ID:               job.Id,
-			URL:              url,
-			Status:           status,
-			DownloadEndpoint: downloadEndpoint,
+			ID:                 job.Id,
+			URL:                url,
+			Status:             status,
+			DownloadEndpoint:   downloadEndpoint,
+			SignedURL:          signedURL,
+			SignedURLExpiresAt: signedURLExpiresAt,
 			VideoMetadata: &VideoMetadata{
-				Title:       title,
-				Description: description,
-				Duration:    duration,
-				VideoID:     videoId,
-				Size:        size,
+				Title:         title,
+				Description:   description,
+				Duration:      duration,
+				VideoID:       videoId,
+				Size:          size,
+				Profile:       download.GetString("profile"),
+				Processing:    processingMetadata(download),
+				TranscriptURL: transcriptURL(download),
 			},
 		})
 	} else {
+		positions, err := downloader.QueuePositions(e.App)
+		if err != nil {
+			e.App.Logger().Error("API: failed to estimate queue positions", "error", err)
+		}
+
 		return e.JSON(200, JobResponse{
-			ID:     job.Id,
-			URL:    url,
-			Status: status,
+			ID:            job.Id,
+			URL:           url,
+			Status:        status,
+			QueuePosition: positions[job.Id],
 		})
 	}
 }
@@ -221,6 +286,12 @@ func downloadHandler(e *core.RequestEvent) error {
 		return e.NotFoundError("job not found", nil)
 	}
 
+	return serveJobDownload(e, job)
+}
+
+// serveJobDownload streams a finished job's file. It answers Range, HEAD and
+// conditional requests, so interrupted downloads can be resumed.
+func serveJobDownload(e *core.RequestEvent, job *core.Record) error {
 	if job.GetString("status") != "SUCCESS" {
 		return e.BadRequestError("job has not completed successfully", nil)
 	}
@@ -241,18 +312,38 @@ func downloadHandler(e *core.RequestEvent) error {
 	}
 	defer fsys.Close()
 
-	fileKey := download.BaseFilesPath() + "/" + download.GetString("file")
-	r, err := fsys.GetReader(fileKey)
-	if err != nil {
+	fileName := download.GetString("file")
+	e.Response.Header().Set("Content-Type", audio_profiles.MimeType(fileName))
+	e.Response.Header().Set("ETag", downloadETag(download))
+
+	if err := fsys.Serve(e.Response, e.Request, download.BaseFilesPath()+"/"+fileName, files.ServedName(download)); err != nil {
 		return e.InternalServerError("internal server error", nil)
 	}
-	defer r.Close()
+	return nil
+}
 
-	content := new(bytes.Buffer)
-	_, err = io.Copy(content, r)
-	if err != nil {
-		return e.InternalServerError("internal server error", nil)
+// downloadETag changes whenever the stored file does, including when it is
+// tagged in place under the same name.
+func downloadETag(download *core.Record) string {
+	return `"` + download.Id + "-" + strconv.FormatInt(download.GetDateTime("updated").Time().Unix(), 10) + `"`
+}
+
+func processingMetadata(download *core.Record) *ProcessingMetadata {
+	processing := audio_profiles.ParseProcessing(download.GetString("processing"))
+	if processing.IsZero() {
+		return nil
 	}
 
-	return e.Blob(200, "audio/mpeg", content.Bytes())
+	return &ProcessingMetadata{
+		LoudnessTarget: processing.LoudnessTarget,
+		TrimSilence:    processing.TrimSilence,
+		Speed:          processing.Speed,
+	}
+}
+
+func transcriptURL(download *core.Record) string {
+	if download.GetString("transcript") == "" {
+		return ""
+	}
+	return files.PublicURL("/api/transcripts/" + download.Id)
 }
@@ -16,12 +16,24 @@ func Init(app *pocketbase.PocketBase) error {
 			})
 		}).Bind(apis.RequireAuth())
 
+		se.Router.POST("/api/jobs/{jobId}/cancel", cancelJobHandler).Bind(apis.RequireAuth())
+		se.Router.POST("/api/items/{itemId}/cancel", cancelItemHandler).Bind(apis.RequireAuth())
+		se.Router.PATCH("/api/items/{itemId}", updateItemHandler).Bind(apis.RequireAuth())
+
+		se.Router.GET("/api/chapters/{downloadId}", chaptersHandler)
+		se.Router.GET("/api/transcripts/{downloadId}", transcriptHandler)
+
 		v1 := se.Router.Group("/api/v1")
 
 		v1.GET("/poll/batch/{batchId}", pollBatchHandler)
 		v1.GET("/poll/job/{jobId}", pollJobHandler)
 		v1.POST("/convert", convertHandler).BindFunc(requireValidAPIKey, checkUsageLimits)
 		v1.POST("/download/{jobId}", downloadHandler).BindFunc(requireValidAPIKey)
+		v1.GET("/download/{jobId}", downloadHandler).BindFunc(requireValidAPIKey)
+		v1.HEAD("/download/{jobId}", downloadHandler).BindFunc(requireValidAPIKey)
+		v1.GET("/signed-download/{jobId}", signedDownloadHandler)
+		v1.HEAD("/signed-download/{jobId}", signedDownloadHandler)
+		v1.POST("/jobs/{jobId}/cancel", cancelJobHandler).BindFunc(requireValidAPIKey)
 
 		v1.GET("/get-items/{podcastId}", getItemsHandler).BindFunc(requireValidAPIKey)
 		v1.GET("/list-podcasts", listPodcastsHandler).BindFunc(requireValidAPIKey)
@@ -8,34 +8,45 @@ import (
 )
 
 func requireValidAPIKey(e *core.RequestEvent) error {
+	user, apiKeyRecord, err := findAPIKeyUser(e)
+	if err != nil {
+		return err
+	}
+
+	e.Set("user", user)
+    e.Set("apiKeyRecord", apiKeyRecord)
+
+	return e.Next()
+}
+
+// findAPIKeyUser authenticates the request's bearer API key, returning its
+// user and key record.
+func findAPIKeyUser(e *core.RequestEvent) (*core.Record, *core.Record, error) {
 	authHeader := e.Request.Header.Get("Authorization")
 	if authHeader == "" {
-		return e.UnauthorizedError("Missing Authorization header", nil)
+		return nil, nil, e.UnauthorizedError("Missing Authorization header", nil)
 	}
 
 	apiKey := ""
 	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
 		apiKey = authHeader[7:]
 	} else {
-		return e.UnauthorizedError("Invalid Authorization header format", nil)
+		return nil, nil, e.UnauthorizedError("Invalid Authorization header format", nil)
 	}
 
 	hashedAPIKey := security.SHA256(apiKey)
 	apiKeyRecord, err := e.App.FindFirstRecordByData(collections.APIKeys, "hashed_key", hashedAPIKey)
 	if err != nil || apiKeyRecord == nil {
-		return e.UnauthorizedError("Invalid API key", nil)
+		return nil, nil, e.UnauthorizedError("Invalid API key", nil)
 	}
 
 	userId := apiKeyRecord.GetString("user")
 	user, err := e.App.FindRecordById(collections.Users, userId)
 	if err != nil || user == nil {
-		return e.UnauthorizedError("Invalid API key", nil)
+		return nil, nil, e.UnauthorizedError("Invalid API key", nil)
 	}
 
-	e.Set("user", user)
-    e.Set("apiKeyRecord", apiKeyRecord)
-
-	return e.Next()
+	return user, apiKeyRecord, nil
 }
 
 func checkUsageLimits(e *core.RequestEvent) error {
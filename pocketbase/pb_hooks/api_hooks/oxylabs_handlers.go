@@ -1,16 +1,16 @@
 package api_hooks
 
 import (
+	"crypto/subtle"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"os"
 
 	"github.com/lsherman98/yt-rss/pocketbase/collections"
+	"github.com/lsherman98/yt-rss/pocketbase/downloader"
 	"github.com/lsherman98/yt-rss/pocketbase/oxylabs"
-	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase/core"
-	"github.com/pocketbase/pocketbase/tools/filesystem"
 	"github.com/pocketbase/pocketbase/tools/routine"
 )
 
@@ -42,6 +42,14 @@ func oxyLabsWebhookHandler(e *core.RequestEvent) error {
 		return e.JSON(http.StatusNotFound, map[string]string{"error": "Queue record not found"})
 	}
 
+	// the secret is set when the job is handed to Oxylabs and travels in the
+	// callback URL, so a guessed queue id is not enough to finish a job
+	secret := queue.GetString("callback_secret")
+	if secret == "" || subtle.ConstantTimeCompare([]byte(secret), []byte(e.Request.URL.Query().Get("secret"))) != 1 {
+		e.App.Logger().Warn("Oxylabs Webhook: rejected callback with invalid secret", "queue_id", queueId)
+		return e.UnauthorizedError("Invalid callback secret", nil)
+	}
+
 	payload := WebhookPayload{}
 	if err := e.BindBody(&payload); err != nil {
 		return e.BadRequestError("Invalid request body", err)
@@ -52,6 +60,19 @@ func oxyLabsWebhookHandler(e *core.RequestEvent) error {
 		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Missing or invalid 'status' in payload"})
 	}
 
+	// callbacks for an earlier Oxylabs job, replays and callbacks that arrive
+	// after the job was finished, cancelled or fell back are acknowledged and
+	// ignored
+	if payload.ID != queue.GetString("oxylab_job_id") {
+		e.App.Logger().Info("Oxylabs Webhook: ignoring callback for another job", "queue_id", queueId, "oxylabs_job_id", payload.ID)
+		return e.JSON(http.StatusOK, map[string]string{"message": "Callback ignored"})
+	}
+
+	if queue.GetString("status") != "PROCESSING" {
+		e.App.Logger().Info("Oxylabs Webhook: ignoring callback for finished job", "queue_id", queueId, "status", queue.GetString("status"))
+		return e.JSON(http.StatusOK, map[string]string{"message": "Callback ignored"})
+	}
+
 	oxylabClient, err := oxylabs.NewClient()
 	if err != nil {
 		return fmt.Errorf("failed to initialize oxylabClient: %w", err)
@@ -61,7 +82,7 @@ func oxyLabsWebhookHandler(e *core.RequestEvent) error {
 	case "pending":
 		handlePending(e.App, queue)
 	case "done":
-		handleDone(e.App, oxylabClient, payload, queue)
+		handleDone(e.App, oxylabClient, queue)
 	case "faulted":
 		handleFaulted(e.App, queue)
 	default:
@@ -75,90 +96,18 @@ func handlePending(app core.App, queue *core.Record) {
 	app.Logger().Info("Oxylabs Webhook: Job pending", "queue_id", queue.Id)
 }
 
-func handleDone(app core.App, oxylabClient *oxylabs.Client, payload WebhookPayload, queue *core.Record) {
-	collection := queue.GetString("collection")
-	recordId := queue.GetString("record_id")
-	record, err := app.FindRecordById(collection, recordId)
-	if err != nil {
-		app.Logger().Error("Oxylabs Webhook: failed to find record for done job", "collection", collection, "record_id", recordId, "error", err)
-		return
-	}
-
+func handleDone(app core.App, oxylabClient *oxylabs.Client, queue *core.Record) {
 	routine.FireAndForget(func() {
-		path, err := oxylabClient.DownloadFile(payload.Query, payload.ID)
-		if err != nil {
-			app.Logger().Error("Oxylabs Webhook: failed to download file", "queue_id", queue.Id, "error", err)
-			return
-		}
-
-		file, err := filesystem.NewFileFromPath(path)
-		if err != nil {
-			return
-		}
-
-		download, err := app.FindFirstRecordByData(collections.Downloads, "video_id", payload.Query)
-		if err != nil {
-			app.Logger().Error("Oxylabs Webhook: failed to find download record", "queue_id", queue.Id, "video_id", payload.Query, "error", err)
-			return
-		}
-
-		download.Set("file", file)
-		download.Set("size", file.Size)
-		if err := app.Save(download); err != nil {
-			return
-		}
-
-		record.Set("download", download.Id)
-		record.Set("status", "SUCCESS")
-		if err := app.Save(record); err != nil {
-			app.Logger().Error("Oxylabs Webhook: failed to save record with downloaded file", "collection", collection, "record_id", recordId, "error", err)
-			return
-		}
-
-		usageRecords, err := app.FindRecordsByFilter(collections.MonthlyUsage, "user = {:user}", "-created", 1, 0, dbx.Params{"user": record.GetString("user")})
-		if err != nil || len(usageRecords) == 0 {
-			app.Logger().Error("Oxylabs Webhook: failed to find monthly usage record", "user", record.GetString("user"), "error", err)
-			return
-		}
-		usage := usageRecords[0]
-
-		usage.Set("usage", usage.GetInt("usage")+int(file.Size))
-		if err := app.Save(usage); err != nil {
-			app.Logger().Error("Downloader: failed to update monthly usage", "error", err)
-		}
-
-		err = os.Remove(path)
-		if err != nil {
-		}
-
-		queue.Set("status", "COMPLETED")
-		if err := app.Save(queue); err != nil {
-			app.Logger().Error("Oxylabs Webhook: failed to update queue record for completed job", "queue_id", queue.Id, "error", err)
-			return
+		err := downloader.CompleteOxylabsJob(app, oxylabClient, queue)
+		if err != nil && !errors.Is(err, downloader.ErrOxylabsJobTaken) {
+			app.Logger().Error("Oxylabs Webhook: failed to complete job", "queue_id", queue.Id, "error", err)
 		}
 	})
 }
 
 func handleFaulted(app core.App, queue *core.Record) {
-	collection := queue.GetString("collection")
-	recordId := queue.GetString("record_id")
-	record, err := app.FindRecordById(collection, recordId)
-	if err != nil {
-		app.Logger().Error("Oxylabs Webhook: failed to find record for faulted job", "collection", collection, "record_id", recordId, "error", err)
-		return
-	}
-
-	record.Set("status", "ERROR")
-	record.Set("error_message", "Oxylabs job faulted")
-	if err := app.Save(record); err != nil {
-		app.Logger().Error("Oxylabs Webhook: failed to update record for faulted job", "collection", collection, "record_id", recordId, "error", err)
-		return
-	}
-
-	queue.Set("status", "FAILED")
-	queue.Set("last_error", "Oxylabs job faulted")
-	if err := app.Save(queue); err != nil {
+	err := downloader.FallBackFromOxylabs(app, queue, "Oxylabs job faulted")
+	if err != nil && !errors.Is(err, downloader.ErrOxylabsJobTaken) {
 		app.Logger().Error("Oxylabs Webhook: failed to update queue record for faulted job", "queue_id", queue.Id, "error", err)
-		return
 	}
 }
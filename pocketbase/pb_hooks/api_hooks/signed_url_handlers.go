@@ -0,0 +1,153 @@
+package api_hooks
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lsherman98/yt-rss/pocketbase/collections"
+	"github.com/lsherman98/yt-rss/pocketbase/files"
+	"github.com/lsherman98/yt-rss/pocketbase/signed_urls"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/security"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// linkSigner signs download links for the jobs of the user whose API key
+// made a poll request.
+type linkSigner struct {
+	user      string
+	ttl       time.Duration
+	singleUse bool
+}
+
+// linkSignerFor reads the signed_url, expires_in and single_use parameters
+// of a poll request. Links are only signed for the owner of the jobs, so
+// asking for them requires the API key. It returns nil when no links were
+// asked for.
+func linkSignerFor(e *core.RequestEvent) (*linkSigner, error) {
+	query := e.Request.URL.Query()
+	if signed, _ := strconv.ParseBool(query.Get("signed_url")); !signed {
+		return nil, nil
+	}
+
+	if !signed_urls.Configured() {
+		return nil, e.BadRequestError("signed download URLs are not enabled", nil)
+	}
+
+	user, _, err := findAPIKeyUser(e)
+	if err != nil {
+		return nil, err
+	}
+
+	singleUse, _ := strconv.ParseBool(query.Get("single_use"))
+	return &linkSigner{
+		user:      user.Id,
+		ttl:       signed_urls.TTL(query.Get("expires_in")),
+		singleUse: singleUse,
+	}, nil
+}
+
+// sign returns a signed link to the job's file and when it expires, or
+// empty strings when the job isn't the signer's.
+func (s *linkSigner) sign(app core.App, job *core.Record) (string, string) {
+	if s == nil || job.GetString("user") != s.user {
+		return "", ""
+	}
+
+	link := signed_urls.Link{JobID: job.Id, Expires: time.Now().Add(s.ttl).Truncate(time.Second)}
+	if s.singleUse {
+		link.Nonce = security.RandomString(32)
+		if err := createDownloadLink(app, link); err != nil {
+			app.Logger().Error("API: failed to create single-use download link", "job_id", job.Id, "error", err)
+			return "", ""
+		}
+	}
+
+	query, err := link.Query()
+	if err != nil {
+		app.Logger().Error("API: failed to sign download link", "job_id", job.Id, "error", err)
+		return "", ""
+	}
+
+	return files.PublicURL("/api/v1/signed-download/" + job.Id + "?" + query), link.Expires.UTC().Format(time.RFC3339)
+}
+
+func createDownloadLink(app core.App, link signed_urls.Link) error {
+	collection, err := app.FindCollectionByNameOrId(collections.DownloadLinks)
+	if err != nil {
+		return err
+	}
+
+	record := core.NewRecord(collection)
+	record.Set("nonce", link.Nonce)
+	record.Set("job", link.JobID)
+	record.Set("expires_at", link.Expires)
+	return app.Save(record)
+}
+
+// signedDownloadHandler serves a job's file to anyone holding a valid signed
+// link, without an API key.
+func signedDownloadHandler(e *core.RequestEvent) error {
+	jobId := e.Request.PathValue("jobId")
+
+	link, err := signed_urls.Verify(jobId, e.Request.URL.Query())
+	if errors.Is(err, signed_urls.ErrExpired) {
+		return e.Error(http.StatusGone, "link has expired", nil)
+	}
+	if err != nil {
+		return e.ForbiddenError("invalid download link", nil)
+	}
+
+	if link.Nonce != "" {
+		if err := claimDownloadLink(e, link); err != nil {
+			return err
+		}
+	}
+
+	job, err := e.App.FindRecordById(collections.Jobs, jobId)
+	if err != nil {
+		return e.NotFoundError("job not found", nil)
+	}
+
+	return serveJobDownload(e, job)
+}
+
+// singleUseWindow is how long a single-use link keeps working after its
+// first request, so that Range follow-ups from players, CDNs and services
+// fetching from several addresses still succeed.
+const singleUseWindow = 5 * time.Minute
+
+// claimDownloadLink marks a single-use link as used on its first request.
+// Any client can keep using it for singleUseWindow after that, or until it
+// expires if that's sooner; then it is gone.
+func claimDownloadLink(e *core.RequestEvent, link signed_urls.Link) error {
+	record, err := e.App.FindFirstRecordByData(collections.DownloadLinks, "nonce", link.Nonce)
+	if err != nil || record.GetString("job") != link.JobID {
+		return e.ForbiddenError("invalid download link", nil)
+	}
+
+	if record.GetDateTime("used_at").IsZero() {
+		_, err := e.App.DB().Update(
+			collections.DownloadLinks,
+			dbx.Params{"used_at": types.NowDateTime().String()},
+			dbx.HashExp{"id": record.Id, "used_at": ""},
+		).Execute()
+		if err != nil {
+			return e.InternalServerError("internal server error", nil)
+		}
+
+		// another request may have used it first
+		record, err = e.App.FindRecordById(collections.DownloadLinks, record.Id)
+		if err != nil {
+			return e.InternalServerError("internal server error", nil)
+		}
+	}
+
+	if time.Since(record.GetDateTime("used_at").Time()) > singleUseWindow {
+		return e.Error(http.StatusGone, "link has already been used", nil)
+	}
+	return nil
+}
@@ -1,17 +1,26 @@
 package api_hooks
 
 type ConvertRequest struct {
-	URLs []string `json:"urls"`
+	URLs           []string `json:"urls"`
+	AudioProfile   string   `json:"audio_profile,omitempty"`
+	LoudnessTarget float64  `json:"loudness_target,omitempty"`
+	TrimSilence    bool     `json:"trim_silence,omitempty"`
+	Speed          float64  `json:"speed,omitempty"`
 }
 
 type JobResponse struct {
-	ID               string         `json:"id"`
-	URL              string         `json:"url"`
-	Status           string         `json:"status"`
-	DownloadEndpoint string         `json:"download_endpoint,omitempty"`
-	VideoMetadata    *VideoMetadata `json:"video_metadata,omitempty"`
-	Title            string         `json:"title,omitempty"`
-	Created          string         `json:"created,omitempty"`
+	ID               string `json:"id"`
+	URL              string `json:"url"`
+	Status           string `json:"status"`
+	DownloadEndpoint string `json:"download_endpoint,omitempty"`
+	// SignedURL downloads the file without an API key until
+	// SignedURLExpiresAt. It is only set when asked for with signed_url.
+	SignedURL          string         `json:"signed_url,omitempty"`
+	SignedURLExpiresAt string         `json:"signed_url_expires_at,omitempty"`
+	VideoMetadata      *VideoMetadata `json:"video_metadata,omitempty"`
+	Title              string         `json:"title,omitempty"`
+	Created            string         `json:"created,omitempty"`
+	QueuePosition      int            `json:"queue_position,omitempty"`
 }
 
 type VideoMetadata struct {
@@ -20,21 +29,56 @@ type VideoMetadata struct {
 	Duration    int    `json:"duration"`
 	VideoID     string `json:"video_id"`
 	Size        int    `json:"size"`
+	// Profile and Processing describe how the file was encoded.
+	Profile    string              `json:"profile,omitempty"`
+	Processing *ProcessingMetadata `json:"processing,omitempty"`
+	// TranscriptURL links the video's subtitles, timed to the file.
+	TranscriptURL string `json:"transcript_url,omitempty"`
+}
+
+type ProcessingMetadata struct {
+	LoudnessTarget float64 `json:"loudness_target,omitempty"`
+	TrimSilence    bool    `json:"trim_silence,omitempty"`
+	Speed          float64 `json:"speed,omitempty"`
 }
 
 type AddUrlRequestBody struct {
 	PodcastID string `json:"podcast_id"`
 	URL       string `json:"url"`
+	// PublishAt schedules the episode. Times in the past publish it when it
+	// finishes, dated PublishAt.
+	PublishAt string `json:"publish_at,omitempty"`
 }
 
 type ItemResponse struct {
-	Status  string `json:"status"`
-	Title   string `json:"title,omitempty"`
-	Error   string `json:"error,omitempty"`
-	Created string `json:"created,omitempty"`
+	Status        string `json:"status"`
+	Title         string `json:"title,omitempty"`
+	Error         string `json:"error,omitempty"`
+	Created       string `json:"created,omitempty"`
+	QueuePosition int    `json:"queue_position,omitempty"`
+	Publication   string `json:"publication,omitempty"`
+	PublishAt     string `json:"publish_at,omitempty"`
 }
 
 type PodcastResponse struct {
 	ID    string `json:"id"`
 	Title string `json:"title"`
 }
+
+// UpdateItemRequest changes an episode's metadata. Fields left out are kept.
+type UpdateItemRequest struct {
+	CustomTitle *string `json:"custom_title"`
+	ShowNotes   *string `json:"show_notes"`
+	PublishedAt *string `json:"published_at"`
+	Season      *int    `json:"season"`
+	Episode     *int    `json:"episode"`
+	EpisodeType *string `json:"episode_type"`
+	Explicit    *bool   `json:"explicit"`
+	// Publication is draft, scheduled or published. Scheduling takes
+	// PublishAt.
+	Publication *string `json:"publication"`
+	PublishAt   *string `json:"publish_at"`
+	// UseUploadDate publishes the episode at the video's YouTube upload date
+	// instead of PublishedAt.
+	UseUploadDate bool `json:"use_upload_date"`
+}
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/lsherman98/yt-rss/pocketbase/collections"
+	"github.com/lsherman98/yt-rss/pocketbase/rss_utils"
 	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/core"
@@ -51,5 +52,24 @@ func Init(app *pocketbase.PocketBase) error {
 		}
 	})
 
+	app.Cron().MustAdd("CronJobExpiredDownloadLinks", "0 * * * *", func() {
+		expiredLinks, err := app.FindRecordsByFilter(collections.DownloadLinks, "expires_at <= @now", "", 0, 0)
+		if err != nil {
+			return
+		}
+
+		for _, record := range expiredLinks {
+			if err := app.Delete(record); err != nil {
+				app.Logger().Error("Cron: failed to delete expired download link", "id", record.Id, "error", err)
+			}
+		}
+	})
+
+	app.Cron().MustAdd("CronJobPublishScheduledItems", "* * * * *", func() {
+		if err := rss_utils.PublishScheduled(app); err != nil {
+			app.Logger().Error("Cron: failed to publish scheduled items", "error", err)
+		}
+	})
+
 	return nil
 }
@@ -1,10 +1,8 @@
 package file_hooks
 
 import (
-	"regexp"
-	"strings"
-
 	"github.com/lsherman98/yt-rss/pocketbase/collections"
+	"github.com/lsherman98/yt-rss/pocketbase/files"
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/core"
 )
@@ -16,13 +14,7 @@ func Init(app *pocketbase.PocketBase) error {
 		case collections.Podcasts:
 			e.Response.Header().Add("Content-Disposition", "inline")
 		case collections.Downloads, collections.Uploads:
-			title := e.Record.GetString("title")
-			cleanTitle := strings.ReplaceAll(title, " ", "_")
-			cleanTitle = regexp.MustCompile(`[^a-zA-Z0-9_-]`).ReplaceAllString(cleanTitle, "")
-			if len(cleanTitle) > 200 {
-				cleanTitle = cleanTitle[:200]
-			}
-			e.ServedName = cleanTitle + ".mp3"
+			e.ServedName = files.ServedName(e.Record)
 		}
 		return e.Next()
 	})
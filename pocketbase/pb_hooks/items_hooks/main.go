@@ -2,16 +2,15 @@ package items_hooks
 
 import (
 	"regexp"
-	"time"
 
 	"github.com/lsherman98/yt-rss/pocketbase/collections"
 	"github.com/lsherman98/yt-rss/pocketbase/downloader"
-	"github.com/lsherman98/yt-rss/pocketbase/files"
 	"github.com/lsherman98/yt-rss/pocketbase/rss_utils"
 	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/core"
 	"github.com/pocketbase/pocketbase/tools/routine"
+	"github.com/pocketbase/pocketbase/tools/types"
 )
 
 func Init(app *pocketbase.PocketBase) error {
@@ -23,6 +22,12 @@ func Init(app *pocketbase.PocketBase) error {
 			return e.BadRequestError("invalid YouTube URL", nil)
 		}
 
+		if publication := e.Record.GetString("publication"); publication != "" {
+			if err := rss_utils.SetPublication(e.Record, publication, e.Record.GetDateTime("publish_at")); err != nil {
+				return e.BadRequestError(err.Error(), nil)
+			}
+		}
+
 		monthlyUsageRecords, err := e.App.FindRecordsByFilter(collections.MonthlyUsage, "user = {:user}", "-created", 1, 0, dbx.Params{
 			"user": e.Auth.Id,
 		})
@@ -41,6 +46,15 @@ func Init(app *pocketbase.PocketBase) error {
 		return e.Next()
 	})
 
+	// items are published as soon as they finish unless created as drafts or
+	// scheduled
+	app.OnRecordCreate(collections.Items).BindFunc(func(e *core.RecordEvent) error {
+		if e.Record.GetString("publication") == "" {
+			e.Record.Set("publication", rss_utils.Published)
+		}
+		return e.Next()
+	})
+
 	app.OnRecordAfterCreateSuccess(collections.Items).BindFunc(func(e *core.RecordEvent) error {
 		itemRecord := e.Record
 		podcastId := itemRecord.GetString("podcast")
@@ -61,21 +75,6 @@ func Init(app *pocketbase.PocketBase) error {
 				return e.Next()
 			}
 
-			fileClient, err := files.NewFileClient(e.App, podcast, "file")
-			if err != nil {
-				return e.Next()
-			}
-
-			content, err := fileClient.GetXMLFile()
-			if err != nil {
-				return e.Next()
-			}
-
-			p, err := rss_utils.ParseXML(content.String())
-			if err != nil {
-				return e.Next()
-			}
-
 			monthlyUsageRecords, err := e.App.FindRecordsByFilter(collections.MonthlyUsage, "user = {:user}", "-created", 1, 0, dbx.Params{
 				"user": user,
 			})
@@ -85,26 +84,17 @@ func Init(app *pocketbase.PocketBase) error {
 			monthlyUsage := monthlyUsageRecords[0]
 			currentUploadCount := monthlyUsage.GetInt("uploads")
 
-			upload, err := e.App.FindRecordById(collections.Uploads, itemRecord.GetString("upload"))
-			if err != nil {
-				return e.Next()
-			}
-
 			itemRecord.Set("status", "SUCCESS")
+			if itemRecord.GetString("publication") == rss_utils.Published {
+				itemRecord.Set("published_at", types.NowDateTime())
+			}
 			if err := e.App.Save(itemRecord); err != nil {
 				return e.Next()
 			}
 
-			audioURL := fileClient.GetFileURL(upload, "file")
-			title := upload.GetString("title")
-			duration := upload.GetFloat("duration")
-			now := time.Now()
-
-			rss_utils.AddItemToPodcast(&p, title, audioURL, "No description provided.", upload.Id, audioURL, int64(duration), &now)
-
 			routine.FireAndForget(func() {
-				if err := rss_utils.UpdateXMLFile(e.App, fileClient, p, podcast); err != nil {
-					e.App.Logger().Error("Items Hooks: failed to update XML file: " + err.Error())
+				if err := rss_utils.RebuildFeed(e.App, podcast); err != nil {
+					e.App.Logger().Error("Items Hooks: failed to rebuild feed: " + err.Error())
 					return
 				}
 			})
@@ -119,44 +109,13 @@ func Init(app *pocketbase.PocketBase) error {
 	})
 
 	app.OnRecordAfterDeleteSuccess(collections.Items).BindFunc(func(e *core.RecordEvent) error {
-		itemType := e.Record.GetString("type")
-		podcastId := e.Record.GetString("podcast")
-		downloadId := e.Record.GetString("download")
-		uploadId := e.Record.GetString("upload")
-
-		podcast, err := e.App.FindRecordById(collections.Podcasts, podcastId)
-		if err != nil {
-			return e.Next()
-		}
-
-		fileClient, err := files.NewFileClient(e.App, podcast, "file")
-		if err != nil {
-			return e.Next()
-		}
-
-		content, err := fileClient.GetXMLFile()
-		if err != nil {
-			return e.Next()
-		}
-
-		p, err := rss_utils.ParseXML(content.String())
+		podcast, err := e.App.FindRecordById(collections.Podcasts, e.Record.GetString("podcast"))
 		if err != nil {
 			return e.Next()
 		}
 
-		switch itemType {
-		case "url":
-			rss_utils.RemoveItemFromPodcast(&p, downloadId)
-		case "upload":
-			rss_utils.RemoveItemFromPodcast(&p, uploadId)
-		}
-
-		xml, err := rss_utils.GenerateXML(&p)
-		if err != nil {
-			return e.Next()
-		}
-		if err := fileClient.SetXMLFile(xml); err != nil {
-			return e.Next()
+		if err := rss_utils.RebuildFeed(e.App, podcast); err != nil {
+			e.App.Logger().Error("Items Hooks: failed to rebuild feed: " + err.Error())
 		}
 
 		return e.Next()
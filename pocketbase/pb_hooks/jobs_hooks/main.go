@@ -82,6 +82,11 @@ func Init(app *pocketbase.PocketBase) error {
 			if err != nil {
 				e.App.Logger().Error("Jobs Hooks: failed to send ERROR webhook notification", "error", err)
 			}
+		case "CANCELLED":
+			err := webhookClient.Send("CANCELLED")
+			if err != nil {
+				e.App.Logger().Error("Jobs Hooks: failed to send CANCELLED webhook notification", "error", err)
+			}
 		}
 
 		return e.Next()
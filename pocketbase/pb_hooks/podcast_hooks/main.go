@@ -2,7 +2,6 @@ package podcast_hooks
 
 import (
 	"github.com/lsherman98/yt-rss/pocketbase/collections"
-	"github.com/lsherman98/yt-rss/pocketbase/files"
 	"github.com/lsherman98/yt-rss/pocketbase/rss_utils"
 	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase"
@@ -34,11 +33,10 @@ func Init(app *pocketbase.PocketBase) error {
 		}
 
 		podcast := e.Record
-		title := podcast.GetString("title")
-		description := podcast.GetString("description")
-		website := podcast.GetString("website")
-		username := e.Auth.GetString("name")
-		email := e.Auth.Email()
+		if err := rss_utils.ValidateChannel(podcast); err != nil {
+			return e.BadRequestError(err.Error(), nil)
+		}
+
 		image := podcast.GetString("image")
 
 		if image == "" {
@@ -54,84 +52,27 @@ func Init(app *pocketbase.PocketBase) error {
 			return e.Next()
 		}
 
-		fileClient, err := files.NewFileClient(e.App, podcast, "file")
-		if err != nil {
-			return e.Next()
-		}
-		defer fileClient.Close()
-
-		p := rss_utils.NewPodcast(
-			title,
-			website,
-			description,
-			username,
-			email,
-			fileClient.GetFileURL(podcast, "image"),
-		)
-
-		xml, err := rss_utils.GenerateXML(&p)
-		if err != nil {
-			return e.Next()
+		if err := rss_utils.RebuildFeed(e.App, podcast); err != nil {
+			e.App.Logger().Error("Podcast Hooks: failed to build feed: " + err.Error())
 		}
 
-		xmlFile, err := fileClient.NewXMLFile(xml, podcast.Id)
-		if err != nil {
-			return e.Next()
-		}
+		return e.Next()
+	})
 
-		podcast.Set("file", xmlFile)
-		if err := e.App.Save(podcast); err != nil {
-			return e.Next()
+	app.OnRecordUpdateRequest(collections.Podcasts).BindFunc(func(e *core.RecordRequestEvent) error {
+		if err := rss_utils.ValidateChannel(e.Record); err != nil {
+			return e.BadRequestError(err.Error(), nil)
 		}
 
 		return e.Next()
 	})
 
+	// the feed is rendered from the podcast, so any change to it rebuilds the
+	// feed. Rebuilding overwrites the file in place and doesn't save the
+	// podcast again.
 	app.OnRecordAfterUpdateSuccess(collections.Podcasts).BindFunc(func(e *core.RecordEvent) error {
-		podcast := e.Record
-		title := podcast.GetString("title")
-		description := podcast.GetString("description")
-		website := podcast.GetString("website")
-
-		fileClient, err := files.NewFileClient(e.App, podcast, "file")
-		if err != nil {
-			return e.Next()
-		}
-		defer fileClient.Close()
-
-		content, err := fileClient.GetXMLFile()
-		if err != nil {
-			return e.Next()
-		}
-
-		p, err := rss_utils.ParseXML(content.String())
-		if err != nil {
-			return e.Next()
-		}
-
-		imageUrl := fileClient.GetFileURL(podcast, "image")
-		if p.Title == title && p.Link == website && p.Description == description && p.Image.URL == imageUrl {
-			return e.Next()
-		}
-
-		p.Title = title
-		p.Link = website
-		p.Description = description
-		p.AddImage(imageUrl)
-
-		xml, err := rss_utils.GenerateXML(&p)
-		if err != nil {
-			return e.Next()
-		}
-
-		xmlFile, err := fileClient.NewXMLFile(xml, podcast.Id)
-		if err != nil {
-			return e.Next()
-		}
-
-		podcast.Set("file", xmlFile)
-		if err := e.App.Save(podcast); err != nil {
-			return e.Next()
+		if err := rss_utils.RebuildFeed(e.App, e.Record); err != nil {
+			e.App.Logger().Error("Podcast Hooks: failed to rebuild feed: " + err.Error())
 		}
 
 		return e.Next()
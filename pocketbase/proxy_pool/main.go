@@ -0,0 +1,262 @@
+package proxy_pool
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"sync"
+
+	"github.com/lsherman98/yt-rss/pocketbase/collections"
+	"github.com/lsherman98/yt-rss/pocketbase/fetch_errors"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// DirectLabel is recorded when no proxy is configured.
+const DirectLabel = "direct"
+
+// ErrNoProxyAvailable is returned when every enabled proxy is at its max
+// concurrency. Going direct would use the server's own address, so the
+// attempt is retried later instead.
+var ErrNoProxyAvailable = fetch_errors.New(fetch_errors.RateLimited, "The download service is busy, please try again later.", errors.New("every proxy is at its max concurrency"))
+
+type Proxy struct {
+	ID    string
+	Label string
+	URL   string
+}
+
+// Pool hands out proxies from the proxies collection. Health is persisted on
+// the proxy records; in-flight counts are tracked per process.
+type Pool struct {
+	app      core.App
+	mu       sync.Mutex
+	inFlight map[string]int
+}
+
+func New(app core.App) *Pool {
+	return &Pool{
+		app:      app,
+		inFlight: map[string]int{},
+	}
+}
+
+// Lease is a proxy checked out for a single attempt. Release must be called
+// once the attempt is over.
+type Lease struct {
+	Proxy
+	pool     *Pool
+	released bool
+}
+
+// Direct returns a lease for connecting without a proxy.
+func Direct() *Lease {
+	return &Lease{Proxy: Proxy{Label: DirectLabel}}
+}
+
+// Acquire picks a proxy for the next attempt. The preferred label is reused if
+// it is still available; the excluded label is skipped unless it is the only
+// proxy left. Among the rest, proxies are picked at random weighted by
+// weight * health. Without enabled proxies the lease is direct.
+func (p *Pool) Acquire(prefer, exclude string) (*Lease, error) {
+	records, err := p.app.FindAllRecords(collections.Proxies, dbx.HashExp{"enabled": true})
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return Direct(), nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	available := []*core.Record{}
+	for _, r := range records {
+		maxConcurrency := r.GetInt("max_concurrency")
+		if maxConcurrency > 0 && p.inFlight[r.Id] >= maxConcurrency {
+			continue
+		}
+		if r.GetString("label") == prefer && prefer != exclude {
+			return p.checkout(r), nil
+		}
+		available = append(available, r)
+	}
+
+	// the excluded proxy is only left out when another one has capacity
+	candidates := []*core.Record{}
+	for _, r := range available {
+		if r.GetString("label") != exclude {
+			candidates = append(candidates, r)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = available
+	}
+
+	if len(candidates) == 0 {
+		p.app.Logger().Warn("ProxyPool: every proxy is at its max concurrency", "proxies", len(records))
+		return nil, ErrNoProxyAvailable
+	}
+
+	total := 0.0
+	scores := make([]float64, len(candidates))
+	for i, r := range candidates {
+		scores[i] = score(r)
+		total += scores[i]
+	}
+
+	pick := rand.Float64() * total
+	for i, r := range candidates {
+		pick -= scores[i]
+		if pick <= 0 {
+			return p.checkout(r), nil
+		}
+	}
+
+	return p.checkout(candidates[len(candidates)-1]), nil
+}
+
+func (p *Pool) checkout(r *core.Record) *Lease {
+	p.inFlight[r.Id]++
+	return &Lease{
+		Proxy: Proxy{
+			ID:    r.Id,
+			Label: r.GetString("label"),
+			URL:   r.GetString("url"),
+		},
+		pool: p,
+	}
+}
+
+// Release records the outcome of the attempt. Permanent errors such as a
+// private video, and cancelled attempts, say nothing about the proxy and don't
+// count towards its health.
+func (l *Lease) Release(attemptErr error) {
+	if !l.checkin() {
+		return
+	}
+
+	if attemptErr != nil && (fetch_errors.KindOf(attemptErr) == fetch_errors.Permanent || errors.Is(attemptErr, context.Canceled)) {
+		return
+	}
+
+	errMessage := ""
+	var query *dbx.Query
+	if attemptErr == nil {
+		query = l.pool.app.DB().NewQuery(`
+			UPDATE proxies
+			SET successes = successes + 1, consecutive_failures = 0, last_used = {:now}
+			WHERE id = {:id}
+		`)
+	} else {
+		errMessage = attemptErr.Error()
+		query = l.pool.app.DB().NewQuery(`
+			UPDATE proxies
+			SET failures = failures + 1, consecutive_failures = consecutive_failures + 1, last_used = {:now}, last_error = {:error}
+			WHERE id = {:id}
+		`)
+	}
+
+	_, err := query.Bind(dbx.Params{
+		"id":    l.ID,
+		"now":   types.NowDateTime().String(),
+		"error": errMessage,
+	}).Execute()
+	if err != nil {
+		l.pool.app.Logger().Error("ProxyPool: failed to record proxy outcome", "proxy", l.Label, "error", err)
+	}
+}
+
+// Return gives the proxy back without recording an outcome, for attempts
+// that never went through it.
+func (l *Lease) Return() {
+	l.checkin()
+}
+
+// checkin frees the proxy's slot. It reports false for direct leases and
+// leases that were already given back.
+func (l *Lease) checkin() bool {
+	if l.pool == nil || l.released {
+		return false
+	}
+	l.released = true
+
+	l.pool.mu.Lock()
+	if l.pool.inFlight[l.ID] > 0 {
+		l.pool.inFlight[l.ID]--
+	}
+	l.pool.mu.Unlock()
+	return true
+}
+
+// score is the proxy's weight times its smoothed success rate, halved for
+// every consecutive failure so that a proxy that just got blocked is avoided.
+func score(r *core.Record) float64 {
+	weight := r.GetFloat("weight")
+	if weight <= 0 {
+		weight = 1
+	}
+
+	return weight * successRate(r) * math.Pow(0.5, float64(r.GetInt("consecutive_failures")))
+}
+
+func successRate(r *core.Record) float64 {
+	successes := r.GetFloat("successes")
+	failures := r.GetFloat("failures")
+	return (successes + 1) / (successes + failures + 2)
+}
+
+type ReportEntry struct {
+	Label               string  `json:"label"`
+	Enabled             bool    `json:"enabled"`
+	Weight              float64 `json:"weight"`
+	MaxConcurrency      int     `json:"max_concurrency"`
+	InFlight            int     `json:"in_flight"`
+	Successes           int     `json:"successes"`
+	Failures            int     `json:"failures"`
+	ConsecutiveFailures int     `json:"consecutive_failures"`
+	SuccessRate         float64 `json:"success_rate"`
+	Score               float64 `json:"score"`
+	LastUsed            string  `json:"last_used"`
+	LastError           string  `json:"last_error"`
+}
+
+func (p *Pool) Report() ([]ReportEntry, error) {
+	records, err := p.app.FindRecordsByFilter(collections.Proxies, "", "label", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	report := []ReportEntry{}
+	for _, r := range records {
+		successes := r.GetInt("successes")
+		failures := r.GetInt("failures")
+
+		rate := 0.0
+		if successes+failures > 0 {
+			rate = float64(successes) / float64(successes+failures)
+		}
+
+		report = append(report, ReportEntry{
+			Label:               r.GetString("label"),
+			Enabled:             r.GetBool("enabled"),
+			Weight:              r.GetFloat("weight"),
+			MaxConcurrency:      r.GetInt("max_concurrency"),
+			InFlight:            p.inFlight[r.Id],
+			Successes:           successes,
+			Failures:            failures,
+			ConsecutiveFailures: r.GetInt("consecutive_failures"),
+			SuccessRate:         rate,
+			Score:               score(r),
+			LastUsed:            r.GetString("last_used"),
+			LastError:           r.GetString("last_error"),
+		})
+	}
+
+	return report, nil
+}
@@ -0,0 +1,232 @@
+package rss_utils
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/eduncan911/podcast"
+	"github.com/google/uuid"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// guidNamespace is the Podcasting 2.0 namespace for podcast:guid, a UUIDv5 of
+// the feed URL.
+var guidNamespace = uuid.MustParse("ead4c236-bf58-58c6-a2c6-a6b28d128cb6")
+
+// defaultCategory is used for podcasts without categories, which Apple
+// requires at least one of.
+var defaultCategory = Category{Category: "Technology"}
+
+// Categories are Apple Podcasts' categories and their subcategories.
+var Categories = map[string][]string{
+	"Arts":                    {"Books", "Design", "Fashion & Beauty", "Food", "Performing Arts", "Visual Arts"},
+	"Business":                {"Careers", "Entrepreneurship", "Investing", "Management", "Marketing", "Non-Profit"},
+	"Comedy":                  {"Comedy Interviews", "Improv", "Stand-Up"},
+	"Education":               {"Courses", "How To", "Language Learning", "Self-Improvement"},
+	"Fiction":                 {"Comedy Fiction", "Drama", "Science Fiction"},
+	"Government":              {},
+	"History":                 {},
+	"Health & Fitness":        {"Alternative Health", "Fitness", "Medicine", "Mental Health", "Nutrition", "Sexuality"},
+	"Kids & Family":           {"Education for Kids", "Parenting", "Pets & Animals", "Stories for Kids"},
+	"Leisure":                 {"Animation & Manga", "Automotive", "Aviation", "Crafts", "Games", "Hobbies", "Home & Garden", "Video Games"},
+	"Music":                   {"Music Commentary", "Music History", "Music Interviews"},
+	"News":                    {"Business News", "Daily News", "Entertainment News", "News Commentary", "Politics", "Sports News", "Tech News"},
+	"Religion & Spirituality": {"Buddhism", "Christianity", "Hinduism", "Islam", "Judaism", "Religion", "Spirituality"},
+	"Science":                 {"Astronomy", "Chemistry", "Earth Sciences", "Life Sciences", "Mathematics", "Natural Sciences", "Nature", "Physics", "Social Sciences"},
+	"Society & Culture":       {"Documentary", "Personal Journals", "Philosophy", "Places & Travel", "Relationships"},
+	"Sports":                  {"Baseball", "Basketball", "Cricket", "Fantasy Sports", "Football", "Golf", "Hockey", "Rugby", "Running", "Soccer", "Swimming", "Tennis", "Volleyball", "Wilderness", "Wrestling"},
+	"Technology":              {},
+	"True Crime":              {},
+	"TV & Film":               {"After Shows", "Film History", "Film Interviews", "Film Reviews", "TV Reviews"},
+}
+
+// maxCategories is how many categories Apple shows for a podcast.
+const maxCategories = 3
+
+type Category struct {
+	Category    string `json:"category"`
+	Subcategory string `json:"subcategory,omitempty"`
+}
+
+// Funding is a podcast:funding link, such as a donation or membership page.
+type Funding struct {
+	URL   string `json:"url"`
+	Title string `json:"title"`
+}
+
+// Person is a podcast:person credited on the podcast. Role and group follow
+// the Podcasting 2.0 taxonomy and default to host and cast.
+type Person struct {
+	Name  string `json:"name"`
+	Role  string `json:"role,omitempty"`
+	Group string `json:"group,omitempty"`
+	Href  string `json:"href,omitempty"`
+	Img   string `json:"img,omitempty"`
+}
+
+var languagePattern = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+
+// ValidateChannel checks the podcast's feed settings, so that a feed can't be
+// rendered that Apple's validator rejects.
+func ValidateChannel(record *core.Record) error {
+	categories := []Category{}
+	if err := record.UnmarshalJSONField("categories", &categories); err != nil {
+		return errors.New("categories must be a list of categories")
+	}
+	if len(categories) > maxCategories {
+		return fmt.Errorf("a podcast can have at most %d categories", maxCategories)
+	}
+	for _, c := range categories {
+		subcategories, ok := Categories[c.Category]
+		if !ok {
+			return fmt.Errorf("%q is not an Apple Podcasts category", c.Category)
+		}
+		if c.Subcategory != "" && !slices.Contains(subcategories, c.Subcategory) {
+			return fmt.Errorf("%q is not a subcategory of %q", c.Subcategory, c.Category)
+		}
+	}
+
+	if language := record.GetString("language"); language != "" && !languagePattern.MatchString(language) {
+		return fmt.Errorf("%q is not a language code, such as en or en-us", language)
+	}
+
+	if guid := record.GetString("podcast_guid"); guid != "" {
+		if _, err := uuid.Parse(guid); err != nil {
+			return errors.New("podcast_guid must be a UUID")
+		}
+	}
+
+	funding := []Funding{}
+	if err := record.UnmarshalJSONField("funding", &funding); err != nil {
+		return errors.New("funding must be a list of links")
+	}
+	for _, f := range funding {
+		if !isWebURL(f.URL) {
+			return fmt.Errorf("%q is not a funding URL", f.URL)
+		}
+		if len(f.Title) > 128 {
+			return errors.New("funding titles can be at most 128 characters")
+		}
+	}
+
+	persons := []Person{}
+	if err := record.UnmarshalJSONField("persons", &persons); err != nil {
+		return errors.New("persons must be a list of people")
+	}
+	for _, p := range persons {
+		if strings.TrimSpace(p.Name) == "" {
+			return errors.New("every person needs a name")
+		}
+		if p.Href != "" && !isWebURL(p.Href) {
+			return fmt.Errorf("%q is not a URL", p.Href)
+		}
+		if p.Img != "" && !isWebURL(p.Img) {
+			return fmt.Errorf("%q is not a URL", p.Img)
+		}
+	}
+
+	return nil
+}
+
+func isWebURL(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// setChannel applies the podcast's iTunes settings that the feed library
+// supports.
+func setChannel(p *podcast.Podcast, podcastRecord *core.Record) {
+	categories := []Category{}
+	podcastRecord.UnmarshalJSONField("categories", &categories)
+	if len(categories) == 0 {
+		categories = []Category{defaultCategory}
+	}
+	for _, c := range categories {
+		subcategories := []string{}
+		if c.Subcategory != "" {
+			subcategories = append(subcategories, c.Subcategory)
+		}
+		p.AddCategory(c.Category, subcategories)
+	}
+
+	if language := podcastRecord.GetString("language"); language != "" {
+		p.Language = strings.ToLower(language)
+	}
+	p.Copyright = podcastRecord.GetString("copyright")
+
+	p.IExplicit = "false"
+	if podcastRecord.GetBool("explicit") {
+		p.IExplicit = "true"
+	}
+	if podcastRecord.GetBool("itunes_block") {
+		p.IBlock = "Yes"
+	}
+	if podcastRecord.GetBool("itunes_complete") {
+		p.IComplete = "Yes"
+	}
+}
+
+// podcastGUID is the podcast's podcast:guid. Feeds moved here from another
+// host keep their GUID by setting it, the others derive it from the feed URL
+// as the spec asks.
+func podcastGUID(podcastRecord *core.Record, feedURL string) string {
+	if guid := podcastRecord.GetString("podcast_guid"); guid != "" {
+		return strings.ToLower(guid)
+	}
+
+	feedURL = strings.TrimPrefix(strings.TrimPrefix(feedURL, "https://"), "http://")
+	return uuid.NewSHA1(guidNamespace, []byte(strings.TrimRight(feedURL, "/"))).String()
+}
+
+// channelTags are the channel's tags the feed library doesn't know: the
+// itunes:type and the Podcasting 2.0 tags.
+func channelTags(podcastRecord *core.Record, owner *core.Record, feedURL string) string {
+	tags := ""
+	tag := func(s string) {
+		tags += "    " + s + "\n"
+	}
+
+	if itunesType := podcastRecord.GetString("itunes_type"); itunesType != "" {
+		tag(`<itunes:type>` + itunesType + `</itunes:type>`)
+	}
+
+	tag(`<podcast:guid>` + podcastGUID(podcastRecord, feedURL) + `</podcast:guid>`)
+
+	locked := "no"
+	if podcastRecord.GetBool("locked") {
+		locked = "yes"
+	}
+	tag(`<podcast:locked owner="` + html.EscapeString(owner.Email()) + `">` + locked + `</podcast:locked>`)
+
+	funding := []Funding{}
+	podcastRecord.UnmarshalJSONField("funding", &funding)
+	for _, f := range funding {
+		tag(`<podcast:funding url="` + html.EscapeString(f.URL) + `">` + html.EscapeString(f.Title) + `</podcast:funding>`)
+	}
+
+	persons := []Person{}
+	podcastRecord.UnmarshalJSONField("persons", &persons)
+	for _, p := range persons {
+		attrs := ""
+		if p.Role != "" {
+			attrs += ` role="` + html.EscapeString(strings.ToLower(p.Role)) + `"`
+		}
+		if p.Group != "" {
+			attrs += ` group="` + html.EscapeString(strings.ToLower(p.Group)) + `"`
+		}
+		if p.Href != "" {
+			attrs += ` href="` + html.EscapeString(p.Href) + `"`
+		}
+		if p.Img != "" {
+			attrs += ` img="` + html.EscapeString(p.Img) + `"`
+		}
+		tag(`<podcast:person` + attrs + `>` + html.EscapeString(p.Name) + `</podcast:person>`)
+	}
+
+	return tags
+}
@@ -0,0 +1,39 @@
+package rss_utils
+
+import (
+	"fmt"
+
+	"github.com/lsherman98/yt-rss/pocketbase/collections"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/spf13/cobra"
+)
+
+// NewRebuildFeedsCommand creates the rebuild-feeds command, which renders
+// every podcast's feed again from the database.
+func NewRebuildFeedsCommand(app core.App) *cobra.Command {
+	return &cobra.Command{
+		Use:   "rebuild-feeds",
+		Short: "Rebuilds the RSS feed of every podcast",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			podcasts, err := app.FindAllRecords(collections.Podcasts)
+			if err != nil {
+				return err
+			}
+
+			failed := 0
+			for _, podcast := range podcasts {
+				if err := RebuildFeed(app, podcast); err != nil {
+					failed++
+					fmt.Fprintf(cmd.ErrOrStderr(), "failed to rebuild feed of podcast %s: %v\n", podcast.Id, err)
+				}
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "rebuilt %d of %d feeds\n", len(podcasts)-failed, len(podcasts))
+			if failed > 0 {
+				return fmt.Errorf("%d feeds failed to rebuild", failed)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,112 @@
+package rss_utils
+
+import (
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// feedLease is how long a writer holds a feed. Rendering takes well under it,
+// the lease only matters when a writer dies while holding the feed.
+const feedLease = 2 * time.Minute
+
+// bumpFeedVersion records that the podcast's feed needs to be rendered again.
+func bumpFeedVersion(app core.App, podcastId string) error {
+	_, err := app.DB().NewQuery(`
+		UPDATE podcasts
+		SET feed_version = feed_version + 1
+		WHERE id = {:id}
+	`).Bind(dbx.Params{
+		"id": podcastId,
+	}).Execute()
+	return err
+}
+
+// claimFeed takes the feed's lease. The conditional update makes sure only
+// one writer holds it when several instances share the database.
+func claimFeed(app core.App, podcastId string) (bool, error) {
+	now := types.NowDateTime()
+	expires, _ := types.ParseDateTime(time.Now().Add(feedLease))
+
+	res, err := app.DB().NewQuery(`
+		UPDATE podcasts
+		SET feed_lease_expires_at = {:expires}
+		WHERE id = {:id} AND (feed_lease_expires_at = '' OR feed_lease_expires_at < {:now})
+	`).Bind(dbx.Params{
+		"id":      podcastId,
+		"expires": expires.String(),
+		"now":     now.String(),
+	}).Execute()
+	if err != nil {
+		return false, err
+	}
+
+	claimed, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return claimed == 1, nil
+}
+
+// renewFeedLease extends the lease of a writer rendering the feed again.
+func renewFeedLease(app core.App, podcastId string) (bool, error) {
+	expires, _ := types.ParseDateTime(time.Now().Add(feedLease))
+
+	res, err := app.DB().NewQuery(`
+		UPDATE podcasts
+		SET feed_lease_expires_at = {:expires}
+		WHERE id = {:id}
+	`).Bind(dbx.Params{
+		"id":      podcastId,
+		"expires": expires.String(),
+	}).Execute()
+	if err != nil {
+		return false, err
+	}
+
+	renewed, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return renewed == 1, nil
+}
+
+// releaseFeedAt gives up the lease if the feed is still at the version that
+// was rendered. It returns false when a newer version came in, and the feed
+// has to be rendered again.
+func releaseFeedAt(app core.App, podcastId string, version int) (bool, error) {
+	res, err := app.DB().NewQuery(`
+		UPDATE podcasts
+		SET feed_lease_expires_at = ''
+		WHERE id = {:id} AND feed_version = {:version}
+	`).Bind(dbx.Params{
+		"id":      podcastId,
+		"version": version,
+	}).Execute()
+	if err != nil {
+		return false, err
+	}
+
+	released, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return released == 1, nil
+}
+
+// releaseFeed gives up the lease after a failed write, so that the next
+// change doesn't wait for it to expire.
+func releaseFeed(app core.App, podcastId string) {
+	_, err := app.DB().NewQuery(`
+		UPDATE podcasts
+		SET feed_lease_expires_at = ''
+		WHERE id = {:id}
+	`).Bind(dbx.Params{
+		"id": podcastId,
+	}).Execute()
+	if err != nil {
+		app.Logger().Error("RSS: failed to release feed lease", "podcast_id", podcastId, "error", err)
+	}
+}
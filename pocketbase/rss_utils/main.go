@@ -2,133 +2,378 @@ package rss_utils
 
 import (
 	"bytes"
+	"html"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/eduncan911/podcast"
+	"github.com/lsherman98/yt-rss/pocketbase/audio_profiles"
+	"github.com/lsherman98/yt-rss/pocketbase/chapters"
+	"github.com/lsherman98/yt-rss/pocketbase/collections"
 	"github.com/lsherman98/yt-rss/pocketbase/files"
-	"github.com/mmcdole/gofeed/rss"
+	"github.com/lsherman98/yt-rss/pocketbase/transcripts"
+	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase/core"
 	"github.com/pocketbase/pocketbase/tools/routine"
+	"github.com/pocketbase/pocketbase/tools/security"
 )
 
-func NewPodcast(title, link, description, authorName, email, image string) podcast.Podcast {
-	now := time.Now()
-	p := podcast.New(
-		title,
-		link,
-		description,
-		&now,
-		&now,
-	)
-	p.AddAuthor(authorName, email)
-	p.IOwner = &podcast.Author{Name: authorName, Email: email}
-	p.AddImage(image)
-	p.AddCategory("Technology", []string{})
-	p.AddAtomLink(link)
-	return p
+const podcastNamespace = "https://podcastindex.org/namespace/1.0"
+
+// RebuildFeed renders the podcast's feed from the database and stores it as
+// the podcast's file. The file is only a cache of the rendered feed, so it
+// can be rebuilt at any time. Existing files are overwritten in place, which
+// keeps the feed URL.
+//
+// Writes to a feed are serialized across processes. Every call records a new
+// feed version, and only the writer holding the feed's lease renders it. A
+// call that finds the feed leased returns right away, since the holder sees
+// the newer version when it's done and renders the feed again.
+func RebuildFeed(app core.App, podcastRecord *core.Record) error {
+	if err := bumpFeedVersion(app, podcastRecord.Id); err != nil {
+		return err
+	}
+
+	claimed, err := claimFeed(app, podcastRecord.Id)
+	if err != nil || !claimed {
+		return err
+	}
+
+	for {
+		podcast, err := app.FindRecordById(collections.Podcasts, podcastRecord.Id)
+		if err != nil {
+			releaseFeed(app, podcastRecord.Id)
+			return err
+		}
+		version := podcast.GetInt("feed_version")
+
+		if err := writeFeed(app, podcast); err != nil {
+			releaseFeed(app, podcast.Id)
+			return err
+		}
+
+		released, err := releaseFeedAt(app, podcast.Id, version)
+		if err != nil {
+			releaseFeed(app, podcast.Id)
+			return err
+		}
+		if !released {
+			// the feed changed while it was rendered
+			if _, err := renewFeedLease(app, podcast.Id); err != nil {
+				app.Logger().Warn("RSS: failed to renew feed lease", "podcast_id", podcast.Id, "error", err)
+			}
+			continue
+		}
+
+		// callers may save the record they passed in, which shouldn't undo
+		// what was written here
+		podcastRecord.Set("file", podcast.GetString("file"))
+		podcastRecord.Set("feed_version", version)
+		podcastRecord.Set("feed_lease_expires_at", "")
+
+		if podcast.GetString("pocketcasts_url") == "" {
+			routine.FireAndForget(func() {
+				setPocketCastsURL(app, podcast)
+			})
+		}
+		return nil
+	}
 }
 
-func AddItemToPodcast(p *podcast.Podcast, title, url, description, guid, enclosure string, length int64, date *time.Time) {
-	pubDate := date
-	item := podcast.Item{
-		Title:       title,
-		Link:        url,
-		Description: description,
-		PubDate:     pubDate,
-		GUID:        guid,
-		Author:      &podcast.Author{Name: p.IOwner.Name, Email: p.IOwner.Email},
-		Enclosure:   &podcast.Enclosure{URL: enclosure, TypeFormatted: podcast.MP3.String(), Type: podcast.MP3, Length: length},
+// afterRender lets tests widen the window between rendering a feed and
+// uploading it.
+var afterRender = func() {}
+
+// writeFeed renders the feed and uploads it. A podcast without a feed file
+// gets a new one, named before rendering since the feed links itself. The
+// name is set with a direct update so that the podcast isn't saved from a
+// record that may already be out of date.
+func writeFeed(app core.App, podcast *core.Record) error {
+	isNew := podcast.GetString("file") == ""
+	if isNew {
+		podcast.Set("file", podcast.Id+"_"+security.RandomStringWithAlphabet(10, "abcdefghijklmnopqrstuvwxyz0123456789")+".rss")
+	}
+
+	xml, err := RenderFeed(app, podcast)
+	if err != nil {
+		return err
 	}
-	p.AddItem(item)
+	afterRender()
+
+	fsys, err := app.NewFilesystem()
+	if err != nil {
+		return err
+	}
+	defer fsys.Close()
+
+	if err := fsys.Upload([]byte(xml), podcast.BaseFilesPath()+"/"+podcast.GetString("file")); err != nil {
+		return err
+	}
+
+	if isNew {
+		_, err := app.DB().Update(collections.Podcasts, dbx.Params{"file": podcast.GetString("file")}, dbx.HashExp{"id": podcast.Id}).Execute()
+		return err
+	}
+	return nil
 }
 
-func RemoveItemFromPodcast(p *podcast.Podcast, guid string) {
-	for i, item := range p.Items {
-		if item.GUID == guid {
-			p.Items = append(p.Items[:i], p.Items[i+1:]...)
-			break
+// RenderFeed renders the podcast's feed from its record, its owner and its
+// finished, published items. The same rows always render the same feed.
+func RenderFeed(app core.App, podcastRecord *core.Record) (string, error) {
+	owner, err := app.FindRecordById(collections.Users, podcastRecord.GetString("user"))
+	if err != nil {
+		return "", err
+	}
+
+	items, err := app.FindRecordsByFilter(
+		collections.Items,
+		"podcast = {:podcast} && status = 'SUCCESS' && publication = {:published}",
+		"published_at,created",
+		0,
+		0,
+		dbx.Params{"podcast": podcastRecord.Id, "published": Published},
+	)
+	if err != nil {
+		return "", err
+	}
+
+	downloads, err := findRelated(app, items, "download", collections.Downloads)
+	if err != nil {
+		return "", err
+	}
+	uploads, err := findRelated(app, items, "upload", collections.Uploads)
+	if err != nil {
+		return "", err
+	}
+
+	pubDate := podcastRecord.GetDateTime("created").Time()
+	lastBuildDate := podcastRecord.GetDateTime("updated").Time()
+	for _, item := range items {
+		if published := item.GetDateTime("published_at").Time(); published.After(lastBuildDate) {
+			lastBuildDate = published
+		}
+	}
+
+	website := podcastRecord.GetString("website")
+	p := podcast.New(podcastRecord.GetString("title"), website, podcastRecord.GetString("description"), &pubDate, &lastBuildDate)
+	p.AddAuthor(owner.GetString("name"), owner.Email())
+	p.IOwner = &podcast.Author{Name: owner.GetString("name"), Email: owner.Email()}
+	p.AddImage(files.FileURL(podcastRecord, "image"))
+	p.AddAtomLink(website)
+	setChannel(&p, podcastRecord)
+
+	tags := map[string]string{}
+	for _, item := range items {
+		published := item.GetDateTime("published_at").Time()
+		if published.IsZero() {
+			published = item.GetDateTime("created").Time()
+		}
+
+		var e episode
+		downloadTags := ""
+		switch {
+		case downloads[item.GetString("download")] != nil:
+			download := downloads[item.GetString("download")]
+			description := download.GetString("description")
+			if description == "" {
+				description = "No description available."
+			}
+
+			e = episode{
+				guid:        download.Id,
+				title:       download.GetString("title"),
+				description: description,
+				audioURL:    files.FileURL(download, "file"),
+				mimeType:    audio_profiles.MimeType(download.GetString("file")),
+				image:       episodeImage(podcastRecord, download),
+				size:        int64(download.GetInt("size")),
+				duration:    int64(download.GetFloat("duration")),
+			}
+			downloadTags = episodeTags(download)
+
+			// the item's own copy is tagged with the podcast as the album
+			if item.GetString("file") != "" {
+				e.audioURL = files.FileURL(item, "file")
+				e.size = int64(item.GetInt("size"))
+			}
+		case uploads[item.GetString("upload")] != nil:
+			upload := uploads[item.GetString("upload")]
+			e = episode{
+				guid:        upload.Id,
+				title:       upload.GetString("title"),
+				description: "No description provided.",
+				audioURL:    files.FileURL(upload, "file"),
+				mimeType:    audio_profiles.MimeType(upload.GetString("file")),
+				size:        int64(upload.GetInt("size")),
+				duration:    int64(upload.GetFloat("duration")),
+			}
+		default:
+			continue
+		}
+
+		// the item's own metadata takes over from the video's
+		if title := item.GetString("custom_title"); title != "" {
+			e.title = title
+		}
+		if notes := item.GetString("show_notes"); notes != "" {
+			e.description = notes
+		}
+		e.explicit = item.GetBool("explicit")
+		e.published = published
+
+		addEpisode(app, &p, e)
+		if tag := itunesEpisodeTags(item) + downloadTags; tag != "" {
+			tags[e.guid] = tag
 		}
 	}
-}
 
-func GenerateXML(p *podcast.Podcast) (string, error) {
 	var buf bytes.Buffer
 	if err := p.Encode(&buf); err != nil {
 		return "", err
 	}
-	return buf.String(), nil
+	return addPodcastTags(buf.String(), channelTags(podcastRecord, owner, files.FileURL(podcastRecord, "file")), tags), nil
 }
 
-func ParseXML(data string) (podcast.Podcast, error) {
-	fp := rss.Parser{}
-	feed, err := fp.Parse(strings.NewReader(data))
+// findRelated loads the records the items point to in field, by id.
+func findRelated(app core.App, items []*core.Record, field, collection string) (map[string]*core.Record, error) {
+	ids := []string{}
+	for _, item := range items {
+		if id := item.GetString(field); id != "" {
+			ids = append(ids, id)
+		}
+	}
+
+	related := map[string]*core.Record{}
+	if len(ids) == 0 {
+		return related, nil
+	}
+
+	records, err := app.FindRecordsByIds(collection, ids)
 	if err != nil {
-		return podcast.Podcast{}, err
+		return nil, err
 	}
+	for _, record := range records {
+		related[record.Id] = record
+	}
+	return related, nil
+}
 
-	parts := strings.Split(feed.ManagingEditor, " (")
-	email := parts[0]
-	name := strings.TrimSuffix(parts[1], ")")
+type episode struct {
+	guid        string
+	title       string
+	description string
+	audioURL    string
+	mimeType    string
+	// image is empty for episodes showing the podcast's image.
+	image     string
+	size      int64
+	duration  int64
+	published time.Time
+	explicit  bool
+}
 
-	now := time.Now()
-	pubDate := time.Now()
-	if feed.PubDate != "" {
-		pubDate, _ = time.Parse(time.RFC1123Z, feed.PubDate)
+func addEpisode(app core.App, p *podcast.Podcast, e episode) {
+	item := podcast.Item{
+		Title:       e.title,
+		Link:        e.audioURL,
+		Description: e.description,
+		PubDate:     &e.published,
+		GUID:        e.guid,
+		Author:      &podcast.Author{Name: p.IOwner.Name, Email: p.IOwner.Email},
+		Enclosure:   &podcast.Enclosure{URL: e.audioURL, Type: enclosureType(e.mimeType), Length: e.size},
+	}
+	item.AddImage(e.image)
+	item.AddDuration(e.duration)
+	if e.explicit {
+		item.IExplicit = "true"
+	}
+	if _, err := p.AddItem(item); err != nil {
+		app.Logger().Error("RSS: failed to add episode", "guid", e.guid, "error", err)
+		return
 	}
 
-	p := podcast.New(
-		feed.Title,
-		feed.Link,
-		feed.Description,
-		&pubDate,
-		&now,
-	)
-	p.AddAuthor(name, email)
-	p.IOwner = &podcast.Author{Name: name, Email: email}
-	p.AddImage(feed.Image.URL)
-	p.AddCategory("Technology", []string{})
-	p.AddAtomLink(feed.Link)
-
-	for _, item := range feed.Items {
-		var length int64 = 0
-		if item.Enclosure != nil && item.Enclosure.Length != "" {
-			if l, err := strconv.ParseInt(item.Enclosure.Length, 10, 64); err == nil {
-				length = l
-			}
-		}
+	// the library only knows a few MIME types and overwrites the formatted
+	// one on AddItem, so types like audio/ogg are set afterwards
+	p.Items[len(p.Items)-1].Enclosure.TypeFormatted = e.mimeType
+}
 
-		AddItemToPodcast(&p, item.Title, item.Link, item.Description, item.GUID.Value, item.Enclosure.URL, length, item.PubDateParsed)
+func enclosureType(mimeType string) podcast.EnclosureType {
+	if mimeType == podcast.M4A.String() {
+		return podcast.M4A
 	}
+	return podcast.MP3
+}
 
-	return p, nil
+// episodeImage is the artwork shown for a download's episode, or empty when
+// it shows the podcast's image.
+func episodeImage(podcastRecord, download *core.Record) string {
+	if podcastRecord.GetBool("disable_episode_artwork") || download.GetString("artwork") == "" {
+		return ""
+	}
+	return files.FileURL(download, "artwork")
 }
 
-func UpdateXMLFile(app core.App, fileClient *files.FileClient, p podcast.Podcast, podcastRecord *core.Record) error {
-	xml, err := GenerateXML(&p)
-	if err != nil {
-		return err
+var itemPattern = regexp.MustCompile(`(?s)<item>.*?<guid>([^<]*)</guid>.*?</item>`)
+
+// addPodcastTags adds the channel's tags the feed library doesn't know and
+// the Podcasting 2.0 tags of each episode, keyed by guid. The feed library has
+// no Podcasting 2.0 support, so the tags are added to the encoded XML.
+func addPodcastTags(xml string, channel string, episodes map[string]string) string {
+	xml = strings.Replace(xml, "<rss ", `<rss xmlns:podcast="`+podcastNamespace+`" `, 1)
+
+	// channel tags go before the episodes
+	if i := strings.Index(xml, "    <item>"); i >= 0 {
+		xml = xml[:i] + channel + xml[i:]
+	} else {
+		xml = strings.Replace(xml, "  </channel>", channel+"  </channel>", 1)
 	}
 
-	xmlFile, err := fileClient.NewXMLFile(xml, podcastRecord.Id)
-	if err != nil {
-		return err
+	return itemPattern.ReplaceAllStringFunc(xml, func(item string) string {
+		tag, ok := episodes[itemPattern.FindStringSubmatch(item)[1]]
+		if !ok {
+			return item
+		}
+		return strings.TrimSuffix(item, "</item>") + tag + "</item>"
+	})
+}
+
+// itunesEpisodeTags are the item's season, episode number and episode type,
+// which the feed library doesn't know.
+func itunesEpisodeTags(item *core.Record) string {
+	tags := ""
+	if season := item.GetInt("season"); season > 0 {
+		tags += "  <itunes:season>" + strconv.Itoa(season) + "</itunes:season>\n    "
+	}
+	if number := item.GetInt("episode"); number > 0 {
+		tags += "  <itunes:episode>" + strconv.Itoa(number) + "</itunes:episode>\n    "
 	}
+	if episodeType := item.GetString("episode_type"); episodeType != "" {
+		tags += "  <itunes:episodeType>" + episodeType + "</itunes:episodeType>\n    "
+	}
+	return tags
+}
 
-	podcastRecord.Set("file", xmlFile)
-	if err := app.Save(podcastRecord); err != nil {
-		return err
+// episodeTags are the podcast:chapters and podcast:transcript tags of a
+// download with chapters or a transcript.
+func episodeTags(download *core.Record) string {
+	tags := ""
+
+	list := []chapters.Chapter{}
+	if err := download.UnmarshalJSONField("chapters", &list); err == nil && len(list) > 0 {
+		tags += `  <podcast:chapters url="` + files.PublicURL("/api/chapters/"+download.Id) + `" type="` + chapters.MimeType + `"></podcast:chapters>` + "\n    "
 	}
 
-	if podcastRecord.GetString("pocketcasts_url") == "" {
-		routine.FireAndForget(func() {
-			setPocketCastsURL(app, podcastRecord)
-		})
+	if transcript := download.GetString("transcript"); transcript != "" {
+		tags += `  <podcast:transcript url="` + files.PublicURL("/api/transcripts/"+download.Id) + `" type="` + transcripts.MimeType(transcript) + `"`
+		if language := download.GetString("transcript_language"); language != "" {
+			tags += ` language="` + html.EscapeString(language) + `"`
+		}
+		tags += ` rel="captions"></podcast:transcript>` + "\n    "
 	}
 
-	defer fileClient.Close()
-	return nil
+	return tags
 }
 
 type PocketCastsAddFeedReq struct {
@@ -0,0 +1,235 @@
+package rss_utils
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lsherman98/yt-rss/pocketbase/collections"
+	_ "github.com/lsherman98/yt-rss/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tests"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+func saveRecord(app core.App, collection string, fields map[string]any) (*core.Record, error) {
+	c, err := app.FindCollectionByNameOrId(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	record := core.NewRecord(c)
+	record.Load(fields)
+	return record, app.SaveNoValidate(record)
+}
+
+func newRecord(t *testing.T, app core.App, collection string, fields map[string]any) *core.Record {
+	t.Helper()
+
+	record, err := saveRecord(app, collection, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return record
+}
+
+func readFeed(t *testing.T, app core.App, podcastId string) string {
+	t.Helper()
+
+	podcast, err := app.FindRecordById(collections.Podcasts, podcastId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fsys, err := app.NewFilesystem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fsys.Close()
+
+	r, err := fsys.GetReader(podcast.BaseFilesPath() + "/" + podcast.GetString("file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	content, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+// Items finishing together used to overwrite each other's episodes.
+func TestRebuildFeedConcurrentItems(t *testing.T) {
+	app, err := tests.NewTestApp(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Cleanup()
+
+	user := newRecord(t, app, collections.Users, map[string]any{
+		"name":  "Test",
+		"email": "test@example.com",
+	})
+	podcast := newRecord(t, app, collections.Podcasts, map[string]any{
+		"user":        user.Id,
+		"title":       "Test Podcast",
+		"description": "Episodes",
+		"image":       "cover.png",
+		// keeps the feed from being registered with Pocket Casts
+		"pocketcasts_url": "https://pca.st/test",
+	})
+
+	if err := RebuildFeed(app, podcast); err != nil {
+		t.Fatal(err)
+	}
+
+	const count = 50
+
+	// the first writer only uploads once every other writer has returned, so
+	// it would overwrite the feed with only the first episodes if writes
+	// weren't serialized
+	var stalled atomic.Bool
+	var returned atomic.Int32
+	othersReturned := make(chan struct{})
+	afterRender = func() {
+		if stalled.CompareAndSwap(false, true) {
+			<-othersReturned
+		}
+	}
+	defer func() { afterRender = func() {} }()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, count)
+	for i := range count {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// the stalled writer is still in RebuildFeed and never gets here
+			defer func() {
+				if returned.Add(1) == count-1 {
+					close(othersReturned)
+				}
+			}()
+
+			// items keep finishing while earlier ones are written
+			time.Sleep(time.Duration(i) * 2 * time.Millisecond)
+
+			download, err := saveRecord(app, collections.Downloads, map[string]any{
+				"video_id": fmt.Sprintf("video%06d", i),
+				"title":    fmt.Sprintf("Episode %d", i),
+				"channel":  "Test",
+				"file":     fmt.Sprintf("episode%d.mp3", i),
+			})
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			_, err = saveRecord(app, collections.Items, map[string]any{
+				"user":         user.Id,
+				"podcast":      podcast.Id,
+				"type":         "url",
+				"download":     download.Id,
+				"status":       "SUCCESS",
+				"publication":  Published,
+				"published_at": types.NowDateTime(),
+			})
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			itemPodcast, err := app.FindRecordById(collections.Podcasts, podcast.Id)
+			if err != nil {
+				errs <- err
+				return
+			}
+			errs <- RebuildFeed(app, itemPodcast)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	feed := readFeed(t, app, podcast.Id)
+	if episodes := strings.Count(feed, "<item>"); episodes != count {
+		t.Fatalf("expected %d episodes in the feed, got %d", count, episodes)
+	}
+	for i := range count {
+		if !strings.Contains(feed, fmt.Sprintf("<title>Episode %d</title>", i)) {
+			t.Errorf("episode %d is missing from the feed", i)
+		}
+	}
+
+	podcast, err = app.FindRecordById(collections.Podcasts, podcast.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lease := podcast.GetString("feed_lease_expires_at"); lease != "" {
+		t.Errorf("expected the feed lease to be released, got %q", lease)
+	}
+}
+
+// Items are served their own copy of the download's file, which is tagged
+// with their podcast as the album.
+func TestRebuildFeedServesItemFile(t *testing.T) {
+	app, err := tests.NewTestApp(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Cleanup()
+
+	user := newRecord(t, app, collections.Users, map[string]any{
+		"name":  "Test",
+		"email": "test@example.com",
+	})
+	podcast := newRecord(t, app, collections.Podcasts, map[string]any{
+		"user":        user.Id,
+		"title":       "Test Podcast",
+		"description": "Episodes",
+		"image":       "cover.png",
+		// keeps the feed from being registered with Pocket Casts
+		"pocketcasts_url": "https://pca.st/test",
+	})
+	download := newRecord(t, app, collections.Downloads, map[string]any{
+		"video_id": "video000001",
+		"title":    "Episode",
+		"channel":  "Test",
+		"file":     "episode.mp3",
+		"size":     1000,
+	})
+	item := newRecord(t, app, collections.Items, map[string]any{
+		"user":         user.Id,
+		"podcast":      podcast.Id,
+		"type":         "url",
+		"download":     download.Id,
+		"status":       "SUCCESS",
+		"publication":  Published,
+		"published_at": types.NowDateTime(),
+		"file":         "episode.mp3",
+		"size":         1200,
+	})
+
+	if err := RebuildFeed(app, podcast); err != nil {
+		t.Fatal(err)
+	}
+
+	feed := readFeed(t, app, podcast.Id)
+	if !strings.Contains(feed, item.BaseFilesPath()+"/episode.mp3") {
+		t.Error("expected the feed to link the item's file")
+	}
+	if !strings.Contains(feed, `length="1200"`) {
+		t.Error("expected the feed to list the size of the item's file")
+	}
+}
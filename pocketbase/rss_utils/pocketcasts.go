@@ -8,7 +8,9 @@ import (
 	"net/url"
 	"time"
 
+	"github.com/lsherman98/yt-rss/pocketbase/collections"
 	"github.com/lsherman98/yt-rss/pocketbase/files"
+	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase/core"
 )
 
@@ -44,11 +46,7 @@ func setPocketCastsURL(app core.App, podcast *core.Record) {
 	searchURL := fmt.Sprintf("https://pocketcasts.com/search?q=%s", url.QueryEscape(podcastURL))
 
 	if addResp.Status == "ok" {
-		url := addResp.Result.ShareLink
-		podcast.Set("pocketcasts_url", url)
-		if err := app.Save(podcast); err != nil {
-			app.Logger().Error("rss_utils: failed to save podcast with pocketcasts url", "error", err)
-		}
+		savePocketCastsURL(app, podcast, addResp.Result.ShareLink)
 		return
 	}
 
@@ -75,24 +73,29 @@ func setPocketCastsURL(app core.App, podcast *core.Record) {
 			}
 
 			if pollResp.Status == "ok" && pollResp.Result.ShareLink != "" {
-				podcast.Set("pocketcasts_url", pollResp.Result.ShareLink)
-				if err := app.Save(podcast); err != nil {
-					app.Logger().Error("rss_utils: failed to save podcast with pocketcasts url", "error", err)
-				}
+				savePocketCastsURL(app, podcast, pollResp.Result.ShareLink)
 				break
 			} else if pollResp.Status == "error" {
 				app.Logger().Error("rss_utils: Pocketcasts returned error status", "error", pollResp)
-				podcast.Set("pocketcasts_url", searchURL)
-				if err := app.Save(podcast); err != nil {
-					app.Logger().Error("rss_utils: failed to save podcast with pocketcasts url", "error", err)
-				}
+				savePocketCastsURL(app, podcast, searchURL)
 				break
 			}
 		}
 	} else {
-		podcast.Set("pocketcasts_url", searchURL)
-		if err := app.Save(podcast); err != nil {
-			app.Logger().Error("rss_utils: failed to save podcast with pocketcasts url", "error", err)
-		}
+		savePocketCastsURL(app, podcast, searchURL)
+	}
+}
+
+// savePocketCastsURL only writes the pocketcasts_url column. The podcast was
+// loaded while its feed was leased, so saving the whole record would write
+// back a stale feed lease and version.
+func savePocketCastsURL(app core.App, podcast *core.Record, pocketCastsURL string) {
+	_, err := app.DB().Update(
+		collections.Podcasts,
+		dbx.Params{"pocketcasts_url": pocketCastsURL},
+		dbx.HashExp{"id": podcast.Id},
+	).Execute()
+	if err != nil {
+		app.Logger().Error("rss_utils: failed to save podcast with pocketcasts url", "error", err)
 	}
 }
@@ -0,0 +1,91 @@
+package rss_utils
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/lsherman98/yt-rss/pocketbase/collections"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// An item's publication decides whether it's in the feed. Drafts and scheduled
+// items are downloaded like the others, but only published items are listed.
+const (
+	Draft     = "draft"
+	Scheduled = "scheduled"
+	Published = "published"
+)
+
+// SetPublication moves an item to a publication state. Scheduled items are
+// published at publishAt, which has to be in the future. Finished items
+// published now are dated now, unless they already have a date.
+func SetPublication(item *core.Record, publication string, publishAt types.DateTime) error {
+	switch publication {
+	case Draft:
+		item.Set("publish_at", "")
+	case Scheduled:
+		if publishAt.IsZero() || !publishAt.Time().After(time.Now()) {
+			return errors.New("scheduled episodes need a publish time in the future")
+		}
+		item.Set("publish_at", publishAt)
+	case Published:
+		item.Set("publish_at", "")
+		if item.GetString("status") == "SUCCESS" && item.GetDateTime("published_at").IsZero() {
+			item.Set("published_at", types.NowDateTime())
+		}
+	default:
+		return fmt.Errorf("%q is not a publication state", publication)
+	}
+
+	item.Set("publication", publication)
+	return nil
+}
+
+// PublishScheduled publishes the scheduled items whose time has come, dated
+// when they were scheduled for, and rebuilds the feeds they are in. Items
+// still downloading are published too and join the feed once they finish.
+// Items and feeds that fail are logged and left for the next run.
+func PublishScheduled(app core.App) error {
+	items, err := app.FindRecordsByFilter(
+		collections.Items,
+		"publication = {:scheduled} && publish_at != '' && publish_at <= @now",
+		"publish_at",
+		0,
+		0,
+		dbx.Params{"scheduled": Scheduled},
+	)
+	if err != nil {
+		return err
+	}
+
+	podcastIds := []string{}
+	for _, item := range items {
+		item.Set("publication", Published)
+		item.Set("published_at", item.GetDateTime("publish_at"))
+		if err := app.Save(item); err != nil {
+			app.Logger().Error("RSS: failed to publish scheduled item", "item_id", item.Id, "error", err)
+			continue
+		}
+
+		if id := item.GetString("podcast"); !slices.Contains(podcastIds, id) {
+			podcastIds = append(podcastIds, id)
+		}
+	}
+
+	for _, id := range podcastIds {
+		podcastRecord, err := app.FindRecordById(collections.Podcasts, id)
+		if err != nil {
+			app.Logger().Error("RSS: failed to find podcast of scheduled item", "podcast_id", id, "error", err)
+			continue
+		}
+		if err := RebuildFeed(app, podcastRecord); err != nil {
+			app.Logger().Error("RSS: failed to rebuild feed", "podcast_id", id, "error", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package show_notes
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// MaxLength is the most show notes Apple Podcasts displays.
+const MaxLength = 4000
+
+// allowed are the tags podcast apps render in show notes. Other tags are
+// dropped and their text kept.
+var allowed = map[atom.Atom]bool{
+	atom.P:          true,
+	atom.Br:         true,
+	atom.A:          true,
+	atom.B:          true,
+	atom.Strong:     true,
+	atom.I:          true,
+	atom.Em:         true,
+	atom.U:          true,
+	atom.Ul:         true,
+	atom.Ol:         true,
+	atom.Li:         true,
+	atom.Blockquote: true,
+}
+
+// dropped are the tags whose content isn't text and is removed with them.
+var dropped = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Iframe:   true,
+	atom.Object:   true,
+	atom.Embed:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+}
+
+// Sanitize keeps the formatting of show notes written as HTML and removes
+// everything else. Links keep only their href, and only to web and mail
+// addresses.
+func Sanitize(notes string) string {
+	nodes, err := html.ParseFragment(strings.NewReader(notes), &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body})
+	if err != nil {
+		return html.EscapeString(notes)
+	}
+
+	var b strings.Builder
+	for _, n := range nodes {
+		render(&b, n)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func render(b *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(html.EscapeString(n.Data))
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	if dropped[n.DataAtom] {
+		return
+	}
+
+	keep := allowed[n.DataAtom]
+	if keep {
+		b.WriteString("<" + n.Data)
+		if n.DataAtom == atom.A {
+			if href := linkHref(n); href != "" {
+				b.WriteString(` href="` + html.EscapeString(href) + `"`)
+			}
+		}
+		b.WriteString(">")
+	}
+
+	if n.DataAtom == atom.Br {
+		return
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		render(b, c)
+	}
+
+	if keep {
+		b.WriteString("</" + n.Data + ">")
+	}
+}
+
+func linkHref(n *html.Node) string {
+	for _, attr := range n.Attr {
+		if attr.Key != "href" {
+			continue
+		}
+
+		u, err := url.Parse(strings.TrimSpace(attr.Val))
+		if err != nil {
+			return ""
+		}
+		switch u.Scheme {
+		case "http", "https", "mailto":
+			return u.String()
+		}
+	}
+	return ""
+}
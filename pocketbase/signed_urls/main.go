@@ -0,0 +1,102 @@
+package signed_urls
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultTTL = time.Hour
+	MaxTTL     = 7 * 24 * time.Hour
+)
+
+var (
+	ErrNotConfigured = errors.New("signed download URLs are not configured")
+	ErrInvalid       = errors.New("invalid signature")
+	ErrExpired       = errors.New("link has expired")
+)
+
+// Link is a signed download link for a job. Single-use links carry a nonce,
+// which is tracked on a download_links record.
+type Link struct {
+	JobID   string
+	Expires time.Time
+	Nonce   string
+}
+
+func secret() ([]byte, error) {
+	value := os.Getenv("DOWNLOAD_URL_SECRET")
+	if value == "" {
+		return nil, ErrNotConfigured
+	}
+	return []byte(value), nil
+}
+
+func signature(key []byte, l Link) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(l.JobID + "\n" + strconv.FormatInt(l.Expires.Unix(), 10) + "\n" + l.Nonce))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Query returns the signed query string of the link.
+func (l Link) Query() (string, error) {
+	key, err := secret()
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(l.Expires.Unix(), 10))
+	if l.Nonce != "" {
+		query.Set("nonce", l.Nonce)
+	}
+	query.Set("sig", signature(key, l))
+	return query.Encode(), nil
+}
+
+// Verify checks the signature and expiry of a link to the given job.
+func Verify(jobID string, query url.Values) (Link, error) {
+	key, err := secret()
+	if err != nil {
+		return Link{}, err
+	}
+
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		return Link{}, ErrInvalid
+	}
+
+	l := Link{JobID: jobID, Expires: time.Unix(expires, 0), Nonce: query.Get("nonce")}
+	if !hmac.Equal([]byte(signature(key, l)), []byte(query.Get("sig"))) {
+		return Link{}, ErrInvalid
+	}
+	if time.Now().After(l.Expires) {
+		return Link{}, ErrExpired
+	}
+	return l, nil
+}
+
+// TTL reads a requested lifetime in seconds, falling back to DefaultTTL and
+// capped at MaxTTL.
+func TTL(seconds string) time.Duration {
+	n, err := strconv.Atoi(seconds)
+	if err != nil || n <= 0 {
+		return DefaultTTL
+	}
+	if n > int(MaxTTL/time.Second) {
+		return MaxTTL
+	}
+	return time.Duration(n) * time.Second
+}
+
+// Configured reports whether a signing secret is set.
+func Configured() bool {
+	_, err := secret()
+	return err == nil
+}
@@ -0,0 +1,175 @@
+package sponsorblock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+
+	"github.com/lsherman98/yt-rss/pocketbase/fetch_errors"
+)
+
+const defaultAPIURL = "https://sponsor.ajay.app/api/skipSegments"
+
+// Categories are the SponsorBlock segment categories that can be cut.
+var Categories = []string{
+	"sponsor",
+	"selfpromo",
+	"interaction",
+	"intro",
+	"outro",
+	"preview",
+	"music_offtopic",
+	"filler",
+}
+
+type Segment struct {
+	Category string  `json:"category"`
+	Start    float64 `json:"start"`
+	End      float64 `json:"end"`
+}
+
+// Source looks up the segments of a video in the given categories.
+type Source interface {
+	Segments(ctx context.Context, videoID string, categories []string) ([]Segment, error)
+}
+
+// NewSource picks the source from SPONSORBLOCK_SOURCE: "api" (the default)
+// queries SPONSORBLOCK_API_URL, "local" reads <video_id>.json fixtures in the
+// SponsorBlock API format from SPONSORBLOCK_DIR.
+func NewSource() Source {
+	if os.Getenv("SPONSORBLOCK_SOURCE") == "local" {
+		dir := os.Getenv("SPONSORBLOCK_DIR")
+		if dir == "" {
+			dir = filepath.Join("pb_data", "sponsorblock")
+		}
+		return &FileSource{Dir: dir}
+	}
+
+	apiURL := os.Getenv("SPONSORBLOCK_API_URL")
+	if apiURL == "" {
+		apiURL = defaultAPIURL
+	}
+	return &APISource{URL: apiURL, httpClient: &http.Client{}}
+}
+
+// apiSegment is a segment as returned by the skipSegments endpoint.
+type apiSegment struct {
+	Category string     `json:"category"`
+	Segment  [2]float64 `json:"segment"`
+}
+
+type APISource struct {
+	URL        string
+	httpClient *http.Client
+}
+
+func (s *APISource) Segments(ctx context.Context, videoID string, categories []string) ([]Segment, error) {
+	encoded, err := json.Marshal(categories)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	query.Set("videoID", videoID)
+	query.Set("categories", string(encoded))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", s.URL+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fetch_errors.New(fetch_errors.Transient, "", fmt.Errorf("failed to query SponsorBlock: %w", err))
+	}
+	defer resp.Body.Close()
+
+	// SponsorBlock answers 404 when a video has no segments
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fetch_errors.New(fetch_errors.Transient, "", fmt.Errorf("SponsorBlock request failed with status %d: %s", resp.StatusCode, string(body)))
+	}
+
+	return decode(resp.Body, categories)
+}
+
+// FileSource reads segments from <Dir>/<video_id>.json. A missing file means
+// the video has no segments.
+type FileSource struct {
+	Dir string
+}
+
+func (s *FileSource) Segments(ctx context.Context, videoID string, categories []string) ([]Segment, error) {
+	f, err := os.Open(filepath.Join(s.Dir, videoID+".json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return decode(f, categories)
+}
+
+func decode(r io.Reader, categories []string) ([]Segment, error) {
+	raw := []apiSegment{}
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("failed to decode segments: %w", err)
+	}
+
+	segments := []Segment{}
+	for _, s := range raw {
+		if !slices.Contains(categories, s.Category) || s.Segment[1] <= s.Segment[0] {
+			continue
+		}
+		segments = append(segments, Segment{Category: s.Category, Start: s.Segment[0], End: s.Segment[1]})
+	}
+
+	return Merge(segments), nil
+}
+
+// Merge sorts segments and joins overlapping ones, so that no part of the
+// audio is cut twice.
+func Merge(segments []Segment) []Segment {
+	if len(segments) == 0 {
+		return segments
+	}
+
+	sorted := slices.Clone(segments)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+
+	merged := []Segment{sorted[0]}
+	for _, s := range sorted[1:] {
+		last := &merged[len(merged)-1]
+		if s.Start <= last.End {
+			last.End = max(last.End, s.End)
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
+
+// Remove maps a time in the video onto the audio with the segments cut out,
+// which are expected to be merged.
+func Remove(segments []Segment, t float64) float64 {
+	removed := 0.0
+	for _, s := range segments {
+		if s.Start >= t {
+			break
+		}
+		removed += min(s.End, t) - s.Start
+	}
+	return t - removed
+}
@@ -0,0 +1,241 @@
+package transcripts
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/lsherman98/yt-rss/pocketbase/sponsorblock"
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+)
+
+// maxSize caps the subtitle file, a few hours of captions are well below it.
+const maxSize = 10 << 20
+
+// formats are the subtitle formats podcast apps read, in order of preference.
+var formats = []string{"vtt", "srt"}
+
+var mimeTypes = map[string]string{
+	"vtt": "text/vtt",
+	"srt": "application/srt",
+}
+
+// Track is a subtitle file listed in yt-dlp's info JSON.
+type Track struct {
+	Language string
+	Format   string
+	URL      string
+	// Automatic is set for YouTube's generated captions.
+	Automatic bool
+}
+
+type infoJSON struct {
+	Language          string                      `json:"language"`
+	Subtitles         map[string][]subtitleFormat `json:"subtitles"`
+	AutomaticCaptions map[string][]subtitleFormat `json:"automatic_captions"`
+}
+
+type subtitleFormat struct {
+	Ext string `json:"ext"`
+	URL string `json:"url"`
+}
+
+// Pick chooses the subtitles to use as the transcript from yt-dlp's info
+// JSON. Subtitles uploaded with the video are preferred over generated
+// captions, and the video's own language over English and then any other.
+func Pick(raw []byte) (Track, bool) {
+	if len(raw) == 0 {
+		return Track{}, false
+	}
+
+	info := infoJSON{}
+	if err := json.Unmarshal(raw, &info); err != nil {
+		return Track{}, false
+	}
+
+	if track, ok := pick(info.Subtitles, info.Language); ok {
+		return track, true
+	}
+	if track, ok := pick(info.AutomaticCaptions, info.Language); ok {
+		track.Automatic = true
+		return track, true
+	}
+	return Track{}, false
+}
+
+func pick(tracks map[string][]subtitleFormat, videoLanguage string) (Track, bool) {
+	languages := []string{}
+	for language := range tracks {
+		// live chat replays are listed as subtitles
+		if language != "live_chat" {
+			languages = append(languages, language)
+		}
+	}
+	slices.Sort(languages)
+
+	preferred := []string{}
+	if videoLanguage != "" {
+		// generated captions in the spoken language are listed as "-orig",
+		// the others are machine translations of them
+		preferred = append(preferred, videoLanguage+"-orig", videoLanguage)
+	}
+	preferred = append(preferred, "en-orig", "en")
+	languages = append(preferred, languages...)
+
+	for _, language := range languages {
+		for _, format := range formats {
+			for _, f := range tracks[language] {
+				if f.Ext == format && f.URL != "" {
+					return Track{Language: strings.TrimSuffix(language, "-orig"), Format: format, URL: f.URL}, true
+				}
+			}
+		}
+	}
+	return Track{}, false
+}
+
+// Download fetches the track into a file named after the video.
+func Download(ctx context.Context, client *http.Client, track Track, videoID string) (*filesystem.File, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", track.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download subtitles: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("subtitle request failed with status %d", resp.StatusCode)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read subtitles: %w", err)
+	}
+	if len(content) > maxSize {
+		return nil, fmt.Errorf("subtitles are larger than %d bytes", maxSize)
+	}
+
+	return filesystem.NewFileFromBytes(content, videoID+"."+track.Format)
+}
+
+// MimeType returns the transcript type for a subtitle file name, as used in
+// the podcast:transcript tag.
+func MimeType(fileName string) string {
+	ext := strings.TrimPrefix(filepath.Ext(fileName), ".")
+	if mimeType, ok := mimeTypes[strings.ToLower(ext)]; ok {
+		return mimeType
+	}
+	return "text/plain"
+}
+
+var timingPattern = regexp.MustCompile(`^((?:\d+:)?\d{2}:\d{2}[.,]\d{3}) --> ((?:\d+:)?\d{2}:\d{2}[.,]\d{3})(.*)$`)
+
+// inlinePattern matches the word timings of YouTube's generated captions.
+var inlinePattern = regexp.MustCompile(`<((?:\d+:)?\d{2}:\d{2}\.\d{3})>`)
+
+// Retime moves the cues of a WebVTT or SRT file from the video's timeline onto
+// the encoded file's, which is shorter by the cut segments and sped up by
+// speed. Cues that were cut entirely are dropped.
+func Retime(content []byte, cuts []sponsorblock.Segment, speed float64) []byte {
+	if len(cuts) == 0 && (speed == 0 || speed == 1) {
+		return content
+	}
+	if speed == 0 {
+		speed = 1
+	}
+
+	var out bytes.Buffer
+	for _, block := range splitBlocks(content) {
+		retimed, keep := retimeBlock(block, cuts, speed)
+		if !keep {
+			continue
+		}
+		out.WriteString(retimed)
+		out.WriteString("\n\n")
+	}
+	return out.Bytes()
+}
+
+// splitBlocks splits a subtitle file on blank lines into the header and cues.
+func splitBlocks(content []byte) []string {
+	blocks := []string{}
+	current := []string{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSize)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			if len(current) > 0 {
+				blocks = append(blocks, strings.Join(current, "\n"))
+				current = []string{}
+			}
+			continue
+		}
+		current = append(current, line)
+	}
+	if len(current) > 0 {
+		blocks = append(blocks, strings.Join(current, "\n"))
+	}
+	return blocks
+}
+
+func retimeBlock(block string, cuts []sponsorblock.Segment, speed float64) (string, bool) {
+	lines := strings.Split(block, "\n")
+	for i, line := range lines {
+		match := timingPattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+
+		start := sponsorblock.Remove(cuts, parseTimestamp(match[1])) / speed
+		end := sponsorblock.Remove(cuts, parseTimestamp(match[2])) / speed
+		if end <= start {
+			return "", false
+		}
+
+		separator := "."
+		if strings.Contains(match[1], ",") {
+			separator = ","
+		}
+		lines[i] = formatTimestamp(start, separator) + " --> " + formatTimestamp(end, separator) + match[3]
+		for j := i + 1; j < len(lines); j++ {
+			lines[j] = inlinePattern.ReplaceAllStringFunc(lines[j], func(tag string) string {
+				t := sponsorblock.Remove(cuts, parseTimestamp(strings.Trim(tag, "<>"))) / speed
+				return "<" + formatTimestamp(t, ".") + ">"
+			})
+		}
+		return strings.Join(lines, "\n"), true
+	}
+
+	// headers, notes and styles have no timing
+	return block, true
+}
+
+func parseTimestamp(value string) float64 {
+	value = strings.Replace(value, ",", ".", 1)
+	seconds := 0.0
+	for _, part := range strings.Split(value, ":") {
+		n, _ := strconv.ParseFloat(part, 64)
+		seconds = seconds*60 + n
+	}
+	return seconds
+}
+
+func formatTimestamp(seconds float64, separator string) string {
+	ms := int64(seconds*1000 + 0.5)
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, separator, ms%1000)
+}
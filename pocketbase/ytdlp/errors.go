@@ -0,0 +1,80 @@
+package ytdlp
+
+import (
+	"strings"
+
+	"github.com/lsherman98/yt-rss/pocketbase/fetch_errors"
+)
+
+type errorPattern struct {
+	kind    fetch_errors.Kind
+	message string
+	matches []string
+}
+
+// errorPatterns are matched case-insensitively against yt-dlp output, in order.
+var errorPatterns = []errorPattern{
+	{
+		kind:    fetch_errors.ProxyBlocked,
+		message: "YouTube blocked the download server, please try again later.",
+		matches: []string{"confirm you're not a bot", "confirm you’re not a bot", "http error 403", "proxyerror", "unable to connect to proxy", "tunnel connection failed", "407 proxy authentication required"},
+	},
+	{
+		kind:    fetch_errors.RateLimited,
+		message: "YouTube is rate limiting downloads, please try again later.",
+		matches: []string{"http error 429", "too many requests", "rate-limited", "rate limited"},
+	},
+	{
+		kind:    fetch_errors.Permanent,
+		message: "This video is private.",
+		matches: []string{"private video", "this video is private"},
+	},
+	{
+		kind:    fetch_errors.Permanent,
+		message: "This video is only available to channel members.",
+		matches: []string{"members-only", "members only", "join this channel to get access", "available to this channel's members"},
+	},
+	{
+		kind:    fetch_errors.Permanent,
+		message: "This video is age-restricted and cannot be downloaded.",
+		matches: []string{"sign in to confirm your age", "age-restricted", "age restricted", "inappropriate for some users"},
+	},
+	{
+		kind:    fetch_errors.Permanent,
+		message: "This video is not available in this region.",
+		matches: []string{"not available in your country", "not made this video available in your country", "geo restriction", "geo-restricted", "georestricted"},
+	},
+	{
+		kind:    fetch_errors.Permanent,
+		message: "This live stream has not finished yet, please try again once it has ended.",
+		matches: []string{"this live event will begin", "premieres in", "premiere will begin", "live stream recording is not available", "is currently live", "is_live"},
+	},
+	{
+		kind:    fetch_errors.Permanent,
+		message: "This video has been deleted or is unavailable.",
+		matches: []string{"video unavailable", "has been removed", "no longer available", "account associated with this video has been terminated", "this video does not exist"},
+	},
+	{
+		kind:    fetch_errors.Permanent,
+		message: "This URL is not a supported YouTube video.",
+		matches: []string{"unsupported url", "is not a valid url", "incomplete youtube id"},
+	},
+}
+
+// classifyError wraps a yt-dlp error in a fetch_errors.Error based on its output.
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	output := strings.ToLower(err.Error())
+	for _, p := range errorPatterns {
+		for _, match := range p.matches {
+			if strings.Contains(output, match) {
+				return fetch_errors.New(p.kind, p.message, err)
+			}
+		}
+	}
+
+	return fetch_errors.New(fetch_errors.Transient, "", err)
+}
@@ -6,176 +6,83 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
 
+	"github.com/lsherman98/yt-rss/pocketbase/audio_profiles"
 	"github.com/pocketbase/pocketbase/core"
 	"github.com/pocketbase/pocketbase/tools/filesystem"
-	ffmpeg "github.com/u2takey/ffmpeg-go"
 	"github.com/wader/goutubedl"
 )
 
 type Client struct {
-	App                core.App
-	ProxyURL           string
-	BackupProxyOneURL  string
-	BackupProxyTwoURL  string
-	CurrentProxyURL    string
-	CurrentProxy       string
-	BackupProxyOne     string
-	BackupProxyTwo     string
-	ThordataProxyURL   string
-	DecodedProxyURL    string
-	EvomiProxyURLOne   string
-	EvomiProxyURLTwo   string
-	EvomiProxyURLThree string
-	EvomiProxyURLFour  string
-	EvomiProxyURLFive  string
-	EvomiProxyURLSix   string
-	EvomiProxyURLSeven string
-	EvomiProxyURLEight string
+	App          core.App
+	ProxyURL     string
+	CurrentProxy string
 }
 
 func New(app core.App) *Client {
-	dev := os.Getenv("DEV")
-	if dev == "true" {
-		return &Client{
-			App: app,
-		}
-	}
-
-	proxy := os.Getenv("PROXY")
-	backupProxyOne := os.Getenv("BACKUP_PROXY_ONE")
-	backupProxyTwo := os.Getenv("BACKUP_PROXY_TWO")
-
-	primaryURL := getProxyURL(proxy)
-	backupOneURL := getProxyURL(backupProxyOne)
-	backupTwoURL := getProxyURL(backupProxyTwo)
-
-	thordataURL := os.Getenv("THORDATA_PROXY_URL")
-	decodedURL := os.Getenv("DECODO_PROXY_URL")
-	evomiOneURL := os.Getenv("EVOMI_PROXY_URL_ONE")
-	evomiTwoURL := os.Getenv("EVOMI_PROXY_URL_TWO")
-	evomiThreeURL := os.Getenv("EVOMI_PROXY_URL_THREE")
-	evomiFourURL := os.Getenv("EVOMI_PROXY_URL_FOUR")
-	evomiFiveURL := os.Getenv("EVOMI_PROXY_URL_FIVE")
-	evomiSixURL := os.Getenv("EVOMI_PROXY_URL_SIX")
-	evomiSevenURL := os.Getenv("EVOMI_PROXY_URL_SEVEN")
-	evomiEightURL := os.Getenv("EVOMI_PROXY_URL_EIGHT")
-
 	return &Client{
-		App:                app,
-		ProxyURL:           primaryURL,
-		BackupProxyOneURL:  backupOneURL,
-		BackupProxyTwoURL:  backupTwoURL,
-		CurrentProxyURL:    primaryURL,
-		CurrentProxy:       proxy,
-		BackupProxyOne:     backupProxyOne,
-		BackupProxyTwo:     backupProxyTwo,
-		ThordataProxyURL:   thordataURL,
-		DecodedProxyURL:    decodedURL,
-		EvomiProxyURLOne:   evomiOneURL,
-		EvomiProxyURLTwo:   evomiTwoURL,
-		EvomiProxyURLThree: evomiThreeURL,
-		EvomiProxyURLFour:  evomiFourURL,
-		EvomiProxyURLFive:  evomiFiveURL,
-		EvomiProxyURLSix:   evomiSixURL,
-		EvomiProxyURLSeven: evomiSevenURL,
-		EvomiProxyURLEight: evomiEightURL,
+		App: app,
 	}
 }
 
-func (c *Client) SwitchProxy(retryCount int) {
+// SetProxy routes subsequent requests through the given proxy. It is a no-op
+// in dev, where yt-dlp always connects directly.
+func (c *Client) SetProxy(label, url string) {
 	if os.Getenv("DEV") == "true" {
 		return
 	}
 
-	switch {
-	case retryCount >= 33:
-		if c.BackupProxyTwoURL == "" {
-			c.App.Logger().Warn("YTDLP: no backup proxy two configured")
-			return
-		}
-		c.CurrentProxyURL = c.BackupProxyTwoURL
-		c.CurrentProxy = c.BackupProxyTwo
-		c.App.Logger().Info("YTDLP: Switched to backup proxy two", "proxy", c.CurrentProxyURL)
-	case retryCount >= 30:
-		if c.BackupProxyOneURL == "" {
-			c.App.Logger().Warn("YTDLP: no backup proxy one configured")
-			return
-		}
-		c.CurrentProxyURL = c.BackupProxyOneURL
-		c.CurrentProxy = c.BackupProxyOne
-		c.App.Logger().Info("YTDLP: Switched to backup proxy one", "proxy", c.CurrentProxyURL)
-	case retryCount >= 27:
-		c.CurrentProxyURL = c.EvomiProxyURLEight
-		c.CurrentProxy = "evomi 8 (x3)"
-		c.App.Logger().Info("YTDLP: Switched to evomi proxy eight", "proxy", c.CurrentProxyURL)
-	case retryCount >= 24:
-		c.CurrentProxyURL = c.EvomiProxyURLSeven
-		c.CurrentProxy = "evomi 7 (x3)"
-		c.App.Logger().Info("YTDLP: Switched to evomi proxy seven", "proxy", c.CurrentProxyURL)
-	case retryCount >= 21:
-		c.CurrentProxyURL = c.EvomiProxyURLSix
-		c.CurrentProxy = "evomi 6 (x3)"
-		c.App.Logger().Info("YTDLP: Switched to evomi proxy six", "proxy", c.CurrentProxyURL)
-	case retryCount >= 18:
-		c.CurrentProxyURL = c.EvomiProxyURLFive
-		c.CurrentProxy = "evomi 5 (x3)"
-		c.App.Logger().Info("YTDLP: Switched to evomi proxy five", "proxy", c.CurrentProxyURL)
-	case retryCount >= 15:
-		c.CurrentProxyURL = c.EvomiProxyURLFour
-		c.CurrentProxy = "evomi 4"
-		c.App.Logger().Info("YTDLP: Switched to evomi proxy four", "proxy", c.CurrentProxyURL)
-	case retryCount >= 12:
-		c.CurrentProxyURL = c.EvomiProxyURLThree
-		c.CurrentProxy = "evomi 3"
-		c.App.Logger().Info("YTDLP: Switched to evomi proxy three", "proxy", c.CurrentProxyURL)
-	case retryCount >= 9:
-		c.CurrentProxyURL = c.EvomiProxyURLTwo
-		c.CurrentProxy = "evomi 2"
-		c.App.Logger().Info("YTDLP: Switched to evomi proxy two", "proxy", c.CurrentProxyURL)
-	case retryCount >= 6:
-		c.CurrentProxyURL = c.DecodedProxyURL
-		c.CurrentProxy = "decodo"
-		c.App.Logger().Info("YTDLP: Switched to decodo proxy", "proxy", c.CurrentProxyURL)
-	case retryCount >= 3:
-		c.CurrentProxyURL = c.ThordataProxyURL
-		c.CurrentProxy = "thordata"
-		c.App.Logger().Info("YTDLP: Switched to thordata proxy", "proxy", c.CurrentProxyURL)
-	}
+	c.ProxyURL = url
+	c.CurrentProxy = label
+	c.App.Logger().Info("YTDLP: using proxy", "proxy", label)
 }
 
 func (c *Client) GetCurrentProxy() string {
-	return c.CurrentProxyURL
+	return c.ProxyURL
 }
 
 func (c *Client) GetCurrentProxyKey() string {
 	return c.CurrentProxy
 }
 
-func (c *Client) GetInfo(url string) (*goutubedl.Result, error) {
+func (c *Client) GetInfo(ctx context.Context, url string) (*goutubedl.Result, error) {
 	opts := goutubedl.Options{
 		DebugLog: log.New(os.Stderr, "ytdlp: ", log.LstdFlags),
 	}
 
 	if os.Getenv("DEV") != "true" {
-		opts.ProxyUrl = c.CurrentProxyURL
+		opts.ProxyUrl = c.ProxyURL
 	}
 
-	result, err := goutubedl.New(context.Background(), url, opts)
+	result, err := goutubedl.New(ctx, url, opts)
 	if err != nil {
-		return nil, err
+		return nil, classifyError(err)
 	}
 
 	return &result, nil
 }
 
-func (c *Client) Download(url string, result *goutubedl.Result, retryCount int) (*filesystem.File, string, error) {
-	download, err := result.DownloadWithOptions(context.Background(), goutubedl.DownloadOptions{
+// Output is a downloaded and encoded file.
+type Output struct {
+	File *filesystem.File
+	Path string
+	// OriginalPath is the audio as downloaded. It is kept when segments are
+	// cut from it, so that other versions can be encoded without downloading
+	// the video again.
+	OriginalPath string
+}
+
+// Download fetches the audio for result and encodes it with the given
+// profile and processing. Files yt-dlp already delivers in the profile's
+// format are kept as they are when there is no processing to apply.
+func (c *Client) Download(ctx context.Context, url string, result *goutubedl.Result, profile audio_profiles.Profile, processing audio_profiles.Processing) (*Output, error) {
+	download, err := result.DownloadWithOptions(ctx, goutubedl.DownloadOptions{
 		DownloadAudioOnly: true,
-		AudioFormats:      "mp3",
+		AudioFormats:      profile.Extension,
 	})
 	if err != nil {
-		return nil, "", err
+		return nil, classifyError(err)
 	}
 	defer download.Close()
 
@@ -183,26 +90,34 @@ func (c *Client) Download(url string, result *goutubedl.Result, retryCount int)
 	if _, err := os.Stat(directory); os.IsNotExist(err) {
 		err = os.Mkdir(directory, 0755)
 		if err != nil {
-			return nil, "", err
+			return nil, err
 		}
 	}
 
-	path := directory + "/" + result.Info.ID + "_temp.mp3"
+	name := result.Info.ID + "_" + profile.Name
+	if key := processing.Key(); key != "" {
+		name += "_" + strings.NewReplacer(",", "_", ".", "", ":", "_", "+", "_").Replace(key)
+	}
+	path := directory + "/" + name + "_temp." + profile.Extension
 	f, err := os.Create(path)
 	if err != nil {
-		return nil, "", err
+		return nil, err
 	}
 	defer f.Close()
 
 	_, err = io.Copy(f, download)
+	if ctx.Err() != nil {
+		os.Remove(path)
+		return nil, ctx.Err()
+	}
 	if err != nil {
-		return nil, "", err
+		return nil, classifyError(err)
 	}
 
 	tempFile, err := os.Open(path)
 	if err != nil {
 		c.App.Logger().Error("YTDLP: failed to open temporary file for type checking", "error", err)
-		return nil, "", err
+		return nil, err
 	}
 
 	buffer := make([]byte, 512)
@@ -210,59 +125,48 @@ func (c *Client) Download(url string, result *goutubedl.Result, retryCount int)
 	if err != nil && err != io.EOF {
 		c.App.Logger().Error("YTDLP: failed to read temporary file for type checking", "error", err)
 		tempFile.Close()
-		return nil, "", err
+		return nil, err
 	}
 	tempFile.Close()
 
 	contentType := http.DetectContentType(buffer)
 
-	convertedPath := directory + "/" + result.Info.ID + ".mp3"
+	convertedPath := directory + "/" + name + "." + profile.Extension
 
-	if contentType == "audio/mpeg" {
+	if contentType == profile.MimeType && profile.Channels == 0 && processing.IsZero() {
 		err = os.Rename(path, convertedPath)
 		if err != nil {
 			c.App.Logger().Error("YTDLP: failed to rename temporary file", "error", err)
-			return nil, "", err
+			return nil, err
 		}
 	} else {
-		err = ffmpeg.Input(path).
-			Output(convertedPath, ffmpeg.KwArgs{"vn": "", "acodec": "libmp3lame", "ab": "192k"}).
-			OverWriteOutput().ErrorToStdOut().Run()
+		err = audio_profiles.Transcode(path, convertedPath, profile, processing)
 		if err != nil {
 			os.Remove(path)
-			c.App.Logger().Error("YTDLP: ffmpeg conversion failed", "error", err)
-			return nil, "", err
+			c.App.Logger().Error("YTDLP: ffmpeg conversion failed", "profile", profile.Name, "error", err)
+			return nil, err
+		}
+	}
+
+	output := &Output{Path: convertedPath}
+
+	if len(processing.SkipCategories) > 0 {
+		output.OriginalPath = directory + "/" + name + "_original." + profile.Extension
+		if err := os.Rename(path, output.OriginalPath); err != nil {
+			c.App.Logger().Error("YTDLP: failed to keep original file", "error", err)
+			output.OriginalPath = ""
 		}
 	}
 
 	err = os.Remove(path)
-	if err != nil {
+	if err != nil && !os.IsNotExist(err) {
 		c.App.Logger().Error("YTDLP: failed to delete temporary file", "error", err)
 	}
 
-	audio, err := filesystem.NewFileFromPath(convertedPath)
+	output.File, err = filesystem.NewFileFromPath(convertedPath)
 	if err != nil {
-		return nil, "", err
+		return nil, err
 	}
 
-	return audio, convertedPath, nil
-}
-
-func getProxyURL(proxyKey string) string {
-	switch proxyKey {
-	case "ngrok":
-		return os.Getenv("NGROK_PROXY")
-	case "oxylabs":
-		return os.Getenv("OXY_LABS_PROXY_URL")
-	case "iproyal":
-		return os.Getenv("IP_ROYAL_PROXY_URL")
-	case "evomi":
-		return os.Getenv("EVOMI_PROXY_URL_ONE")
-	case "thordata":
-		return os.Getenv("THORDATA_PROXY_URL")
-	case "decodo":
-		return os.Getenv("DECODO_PROXY_URL")
-	default:
-		return ""
-	}
+	return output, nil
 }
@@ -1,19 +1,24 @@
 import { Button } from "@/components/ui/button";
 import { Table, TableBody, TableCell, TableHead, TableHeader, TableRow } from "@/components/ui/table";
-import { Download, AlertCircle, CheckCircle, Loader2, Grip } from "lucide-react";
+import { Download, AlertCircle, CheckCircle, Loader2, Grip, Ban, X } from "lucide-react";
 import type { JobsResponse } from "@/lib/pocketbase-types";
 import { JobsStatusOptions } from "@/lib/pocketbase-types";
 import type { ExpandJobs } from "@/lib/api/api";
 import { pb } from "@/lib/pocketbase";
 import { Badge } from "@/components/ui/badge";
 import { Tooltip, TooltipContent, TooltipProvider, TooltipTrigger } from "@/components/ui/tooltip";
-import { formatFileSize } from "@/lib/utils";
+import { formatFileSize, getNextAttempt } from "@/lib/utils";
+import { useCancelJob } from "@/lib/api/mutations";
 
 interface JobsTableProps {
   jobs: JobsResponse<ExpandJobs>[];
 }
 
+const CANCELLABLE_STATUSES = [JobsStatusOptions.CREATED, JobsStatusOptions.STARTED, JobsStatusOptions.PROCESSING];
+
 export function JobsTable({ jobs }: JobsTableProps) {
+  const cancelJobMutation = useCancelJob();
+
   const handleDownload = (job: JobsResponse<ExpandJobs>) => {
     if (job.download && job.expand?.download) {
       const fileUrl = pb.files.getURL(job.expand.download, job.expand.download.file, { download: true, v: Date.now() });
@@ -61,6 +66,13 @@ export function JobsTable({ jobs }: JobsTableProps) {
             Error
           </Badge>
         );
+      case JobsStatusOptions.CANCELLED:
+        return (
+          <Badge variant="outline" className="flex items-center gap-1.5 text-muted-foreground min-w-26">
+            <Ban className="h-3 w-3" />
+            Cancelled
+          </Badge>
+        );
       default:
         return <Badge variant="outline">{status}</Badge>;
     }
@@ -104,6 +116,19 @@ export function JobsTable({ jobs }: JobsTableProps) {
                         </TooltipContent>
                       </Tooltip>
                     </TooltipProvider>
+                  ) : getNextAttempt(job.next_attempt_at) ? (
+                    <TooltipProvider>
+                      <Tooltip>
+                        <TooltipTrigger asChild>
+                          <div className="cursor-help">{getStatusBadge(job.status)}</div>
+                        </TooltipTrigger>
+                        <TooltipContent className="max-w-md">
+                          <p className="text-sm">
+                            Retrying after {getNextAttempt(job.next_attempt_at)?.toLocaleString()}
+                          </p>
+                        </TooltipContent>
+                      </Tooltip>
+                    </TooltipProvider>
                   ) : (
                     getStatusBadge(job.status)
                   )}
@@ -138,6 +163,17 @@ export function JobsTable({ jobs }: JobsTableProps) {
                       <Download className="h-4 w-4" />
                       Download
                     </Button>
+                  ) : CANCELLABLE_STATUSES.includes(job.status) ? (
+                    <Button
+                      variant="outline"
+                      size="sm"
+                      onClick={() => cancelJobMutation.mutate(job.id)}
+                      disabled={cancelJobMutation.isPending && cancelJobMutation.variables === job.id}
+                      className="flex items-center gap-2"
+                    >
+                      <X className="h-4 w-4" />
+                      Cancel
+                    </Button>
                   ) : (
                     <span className="text-muted-foreground text-sm">-</span>
                   )}
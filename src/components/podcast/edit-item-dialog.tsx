@@ -0,0 +1,247 @@
+import { Edit } from "lucide-react";
+import { Button } from "@/components/ui/button";
+import { Dialog, DialogContent, DialogDescription, DialogHeader, DialogTitle } from "@/components/ui/dialog";
+import { Input } from "@/components/ui/input";
+import { Textarea } from "@/components/ui/textarea";
+import { Label } from "@/components/ui/label";
+import { Switch } from "@/components/ui/switch";
+import { Select, SelectContent, SelectItem, SelectTrigger, SelectValue } from "@/components/ui/select";
+import { useUpdatePodcastItem } from "@/lib/api/mutations";
+import { toast } from "sonner";
+import { useState, useEffect } from "react";
+import {
+  ItemsEpisodeTypeOptions,
+  ItemsPublicationOptions,
+  ItemsTypeOptions,
+  type ItemsResponse,
+} from "@/lib/pocketbase-types";
+import type { ExpandItem, ItemMetadata } from "@/lib/api/api";
+
+// Apple Podcasts shows at most this much of an episode's notes.
+const MAX_SHOW_NOTES = 4000;
+
+// Select items can't have an empty value.
+const DEFAULT_TYPE = "default";
+
+interface EditItemDialogProps {
+  item: ItemsResponse<ExpandItem> | null;
+  open: boolean;
+  onOpenChange: (open: boolean) => void;
+}
+
+// datetime-local inputs take the local time without a timezone.
+function toLocalInput(date?: string) {
+  if (!date) return "";
+  const d = new Date(date);
+  if (isNaN(d.getTime())) return "";
+  const pad = (n: number) => String(n).padStart(2, "0");
+  return `${d.getFullYear()}-${pad(d.getMonth() + 1)}-${pad(d.getDate())}T${pad(d.getHours())}:${pad(d.getMinutes())}`;
+}
+
+function formFromItem(item: ItemsResponse<ExpandItem> | null) {
+  return {
+    custom_title: item?.custom_title || "",
+    show_notes: item?.show_notes || "",
+    published_at: toLocalInput(item?.published_at),
+    season: item?.season ? String(item.season) : "",
+    episode: item?.episode ? String(item.episode) : "",
+    episode_type: (item?.episode_type || DEFAULT_TYPE) as string,
+    explicit: item?.explicit || false,
+    publication: item?.publication || ItemsPublicationOptions.published,
+    publish_at: toLocalInput(item?.publish_at),
+  };
+}
+
+export function EditItemDialog({ item, open, onOpenChange }: EditItemDialogProps) {
+  const [formData, setFormData] = useState(formFromItem(item));
+  const updateItemMutation = useUpdatePodcastItem();
+
+  useEffect(() => {
+    if (item) {
+      setFormData(formFromItem(item));
+    }
+  }, [item]);
+
+  const data = item?.type === ItemsTypeOptions.upload ? item?.expand?.upload : item?.expand?.download;
+  const uploadDate = item?.type === ItemsTypeOptions.url ? item?.expand?.download?.upload_date : "";
+
+  const save = async (extra: Partial<ItemMetadata> = {}) => {
+    if (!item) return;
+
+    const body: ItemMetadata = {
+      custom_title: formData.custom_title.trim(),
+      show_notes: formData.show_notes.trim(),
+      season: formData.season ? Number(formData.season) : 0,
+      episode: formData.episode ? Number(formData.episode) : 0,
+      episode_type: formData.episode_type === DEFAULT_TYPE ? "" : (formData.episode_type as ItemsEpisodeTypeOptions),
+      explicit: formData.explicit,
+      publication: formData.publication,
+      ...extra,
+    };
+    if (formData.publication === ItemsPublicationOptions.scheduled) {
+      if (!formData.publish_at) {
+        toast.error("Pick when to publish the episode");
+        return;
+      }
+      body.publish_at = new Date(formData.publish_at).toISOString();
+    }
+    if (formData.published_at && !extra.use_upload_date) {
+      body.published_at = new Date(formData.published_at).toISOString();
+    }
+
+    try {
+      await updateItemMutation.mutateAsync({ itemId: item.id, data: body });
+      toast.success("Episode updated successfully!");
+      onOpenChange(false);
+    } catch (error: any) {
+      toast.error(error?.response?.message || "Failed to update episode");
+    }
+  };
+
+  return (
+    <Dialog open={open} onOpenChange={onOpenChange}>
+      <DialogContent className="max-w-2xl max-h-[90vh] overflow-y-auto">
+        <DialogHeader>
+          <DialogTitle className="flex items-center gap-2">
+            <Edit className="h-5 w-5" />
+            Edit Episode
+          </DialogTitle>
+          <DialogDescription>How this episode appears in your feed.</DialogDescription>
+        </DialogHeader>
+        <div className="space-y-4">
+          <div>
+            <Label htmlFor="custom_title">Title</Label>
+            <Input
+              id="custom_title"
+              value={formData.custom_title}
+              maxLength={255}
+              onChange={(e) => setFormData({ ...formData, custom_title: e.target.value })}
+              placeholder={data?.title || "Episode title"}
+            />
+          </div>
+          <div>
+            <Label htmlFor="show_notes">Show Notes</Label>
+            <Textarea
+              id="show_notes"
+              value={formData.show_notes}
+              onChange={(e) => setFormData({ ...formData, show_notes: e.target.value })}
+              placeholder="Uses the video description when empty"
+              rows={6}
+            />
+            <p className="text-sm text-muted-foreground mt-1">
+              Links and basic formatting in HTML are kept. {formData.show_notes.length}/{MAX_SHOW_NOTES}
+            </p>
+          </div>
+          <div className="grid grid-cols-2 gap-4">
+            <div>
+              <Label htmlFor="publication">Status</Label>
+              <Select
+                value={formData.publication}
+                onValueChange={(value) =>
+                  setFormData({ ...formData, publication: value as ItemsPublicationOptions })
+                }
+              >
+                <SelectTrigger id="publication" className="w-full">
+                  <SelectValue />
+                </SelectTrigger>
+                <SelectContent>
+                  <SelectItem value={ItemsPublicationOptions.published}>Published</SelectItem>
+                  <SelectItem value={ItemsPublicationOptions.scheduled}>Scheduled</SelectItem>
+                  <SelectItem value={ItemsPublicationOptions.draft}>Draft</SelectItem>
+                </SelectContent>
+              </Select>
+            </div>
+            {formData.publication === ItemsPublicationOptions.scheduled && (
+              <div>
+                <Label htmlFor="publish_at">Publish At</Label>
+                <Input
+                  id="publish_at"
+                  type="datetime-local"
+                  value={formData.publish_at}
+                  onChange={(e) => setFormData({ ...formData, publish_at: e.target.value })}
+                />
+              </div>
+            )}
+          </div>
+          <div>
+            <Label htmlFor="published_at">Publish Date</Label>
+            <div className="flex gap-2">
+              <Input
+                id="published_at"
+                type="datetime-local"
+                value={formData.published_at}
+                onChange={(e) => setFormData({ ...formData, published_at: e.target.value })}
+              />
+              {uploadDate && (
+                <Button
+                  variant="outline"
+                  onClick={() => save({ use_upload_date: true })}
+                  disabled={updateItemMutation.isPending}
+                >
+                  Use YouTube upload date
+                </Button>
+              )}
+            </div>
+          </div>
+          <div className="grid grid-cols-3 gap-4">
+            <div>
+              <Label htmlFor="season">Season</Label>
+              <Input
+                id="season"
+                type="number"
+                min={0}
+                value={formData.season}
+                onChange={(e) => setFormData({ ...formData, season: e.target.value })}
+              />
+            </div>
+            <div>
+              <Label htmlFor="episode">Episode</Label>
+              <Input
+                id="episode"
+                type="number"
+                min={0}
+                value={formData.episode}
+                onChange={(e) => setFormData({ ...formData, episode: e.target.value })}
+              />
+            </div>
+            <div>
+              <Label htmlFor="episode_type">Type</Label>
+              <Select
+                value={formData.episode_type}
+                onValueChange={(value) => setFormData({ ...formData, episode_type: value })}
+              >
+                <SelectTrigger id="episode_type" className="w-full">
+                  <SelectValue />
+                </SelectTrigger>
+                <SelectContent>
+                  <SelectItem value={DEFAULT_TYPE}>Not set</SelectItem>
+                  <SelectItem value={ItemsEpisodeTypeOptions.full}>Full</SelectItem>
+                  <SelectItem value={ItemsEpisodeTypeOptions.trailer}>Trailer</SelectItem>
+                  <SelectItem value={ItemsEpisodeTypeOptions.bonus}>Bonus</SelectItem>
+                </SelectContent>
+              </Select>
+            </div>
+          </div>
+          <div className="flex items-center space-x-2">
+            <Switch
+              id="item_explicit"
+              checked={formData.explicit}
+              onCheckedChange={(checked) => setFormData({ ...formData, explicit: checked })}
+            />
+            <Label htmlFor="item_explicit" className="cursor-pointer">
+              Contains explicit content
+            </Label>
+          </div>
+          <div className="flex justify-end gap-2">
+            <Button variant="outline" onClick={() => onOpenChange(false)}>
+              Cancel
+            </Button>
+            <Button onClick={() => save()} disabled={updateItemMutation.isPending}>
+              {updateItemMutation.isPending ? "Saving..." : "Save Episode"}
+            </Button>
+          </div>
+        </div>
+      </DialogContent>
+    </Dialog>
+  );
+}
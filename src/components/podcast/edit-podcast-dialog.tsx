@@ -11,10 +11,38 @@ import {
 import { Input } from "@/components/ui/input";
 import { Textarea } from "@/components/ui/textarea";
 import { Label } from "@/components/ui/label";
+import { Switch } from "@/components/ui/switch";
+import { Checkbox } from "@/components/ui/checkbox";
+import { Select, SelectContent, SelectItem, SelectTrigger, SelectValue } from "@/components/ui/select";
 import { useUpdatePodcast } from "@/lib/api/mutations";
 import { toast } from "sonner";
 import { useState, useEffect } from "react";
-import type { PodcastsResponse } from "@/lib/pocketbase-types";
+import {
+  PodcastsAudioProfileOptions,
+  PodcastsSpeedOptions,
+  PodcastsSponsorblockCategoriesOptions,
+  type PodcastsResponse,
+} from "@/lib/pocketbase-types";
+
+const AUDIO_PROFILES = [
+  { value: PodcastsAudioProfileOptions["speech-64k-mono-mp3"], label: "Speech (64 kbps mono MP3)" },
+  { value: PodcastsAudioProfileOptions["music-192k-mp3"], label: "Music (192 kbps MP3)" },
+  { value: PodcastsAudioProfileOptions["opus-48k"], label: "Opus (48 kbps)" },
+  { value: PodcastsAudioProfileOptions["aac-m4a"], label: "AAC (M4A)" },
+];
+
+const NORMAL_SPEED = "1";
+
+const SPONSORBLOCK_CATEGORIES = [
+  { value: PodcastsSponsorblockCategoriesOptions.sponsor, label: "Sponsors" },
+  { value: PodcastsSponsorblockCategoriesOptions.selfpromo, label: "Self promotion" },
+  { value: PodcastsSponsorblockCategoriesOptions.interaction, label: "Like and subscribe reminders" },
+  { value: PodcastsSponsorblockCategoriesOptions.intro, label: "Intros" },
+  { value: PodcastsSponsorblockCategoriesOptions.outro, label: "Outros and end cards" },
+  { value: PodcastsSponsorblockCategoriesOptions.preview, label: "Previews and recaps" },
+  { value: PodcastsSponsorblockCategoriesOptions.music_offtopic, label: "Non-music sections" },
+  { value: PodcastsSponsorblockCategoriesOptions.filler, label: "Filler tangents" },
+];
 
 interface EditPodcastDialogProps {
   podcast: PodcastsResponse;
@@ -25,6 +53,13 @@ export function EditPodcastDialog({ podcast }: EditPodcastDialogProps) {
     title: podcast?.title || "",
     description: podcast?.description || "",
     website: podcast?.website || "",
+    audio_profile: podcast?.audio_profile || PodcastsAudioProfileOptions["music-192k-mp3"],
+    loudness_target: podcast?.loudness_target ? String(podcast.loudness_target) : "",
+    trim_silence: podcast?.trim_silence || false,
+    speed: (podcast?.speed || NORMAL_SPEED) as string,
+    sponsorblock_categories: podcast?.sponsorblock_categories || [],
+    episode_artwork: !podcast?.disable_episode_artwork,
+    use_upload_date: podcast?.use_upload_date || false,
     image: null as File | null,
   });
   const [isUpdateDialogOpen, setIsUpdateDialogOpen] = useState(false);
@@ -36,6 +71,13 @@ export function EditPodcastDialog({ podcast }: EditPodcastDialogProps) {
         title: podcast.title || "",
         description: podcast.description || "",
         website: podcast.website || "",
+        audio_profile: podcast.audio_profile || PodcastsAudioProfileOptions["music-192k-mp3"],
+        loudness_target: podcast.loudness_target ? String(podcast.loudness_target) : "",
+        trim_silence: podcast.trim_silence || false,
+        speed: podcast.speed || NORMAL_SPEED,
+        sponsorblock_categories: podcast.sponsorblock_categories || [],
+        episode_artwork: !podcast.disable_episode_artwork,
+        use_upload_date: podcast.use_upload_date || false,
         image: null,
       });
     }
@@ -48,6 +90,13 @@ export function EditPodcastDialog({ podcast }: EditPodcastDialogProps) {
       title: formData.title,
       description: formData.description,
       website: formData.website,
+      audio_profile: formData.audio_profile,
+      loudness_target: formData.loudness_target ? Number(formData.loudness_target) : 0,
+      trim_silence: formData.trim_silence,
+      speed: formData.speed === NORMAL_SPEED ? "" : formData.speed,
+      sponsorblock_categories: formData.sponsorblock_categories,
+      disable_episode_artwork: !formData.episode_artwork,
+      use_upload_date: formData.use_upload_date,
     };
 
     if (formData.image) {
@@ -109,6 +158,89 @@ export function EditPodcastDialog({ podcast }: EditPodcastDialogProps) {
               placeholder="https://example.com"
             />
           </div>
+          <div>
+            <Label htmlFor="audio_profile">Audio Format</Label>
+            <Select
+              value={formData.audio_profile}
+              onValueChange={(value) =>
+                setFormData({ ...formData, audio_profile: value as PodcastsAudioProfileOptions })
+              }
+            >
+              <SelectTrigger id="audio_profile" className="w-full">
+                <SelectValue />
+              </SelectTrigger>
+              <SelectContent>
+                {AUDIO_PROFILES.map((profile) => (
+                  <SelectItem key={profile.value} value={profile.value}>
+                    {profile.label}
+                  </SelectItem>
+                ))}
+              </SelectContent>
+            </Select>
+            <p className="text-sm text-muted-foreground mt-1">Applies to episodes added from now on.</p>
+          </div>
+          <div className="grid grid-cols-2 gap-4">
+            <div>
+              <Label htmlFor="loudness_target">Loudness Target (LUFS)</Label>
+              <Input
+                id="loudness_target"
+                type="number"
+                min={-70}
+                max={-5}
+                value={formData.loudness_target}
+                onChange={(e) => setFormData({ ...formData, loudness_target: e.target.value })}
+                placeholder="Off, e.g. -16"
+              />
+            </div>
+            <div>
+              <Label htmlFor="speed">Playback Speed</Label>
+              <Select value={formData.speed} onValueChange={(value) => setFormData({ ...formData, speed: value })}>
+                <SelectTrigger id="speed" className="w-full">
+                  <SelectValue />
+                </SelectTrigger>
+                <SelectContent>
+                  <SelectItem value={NORMAL_SPEED}>Normal</SelectItem>
+                  <SelectItem value={PodcastsSpeedOptions["1.25"]}>1.25x</SelectItem>
+                  <SelectItem value={PodcastsSpeedOptions["1.5"]}>1.5x</SelectItem>
+                </SelectContent>
+              </Select>
+            </div>
+          </div>
+          <div className="flex items-center space-x-2">
+            <Switch
+              id="trim_silence"
+              checked={formData.trim_silence}
+              onCheckedChange={(checked) => setFormData({ ...formData, trim_silence: checked })}
+            />
+            <Label htmlFor="trim_silence" className="cursor-pointer">
+              Trim leading and trailing silence
+            </Label>
+          </div>
+          <div>
+            <Label>Remove Segments</Label>
+            <p className="text-sm text-muted-foreground mb-2">Cut these parts from new episodes using SponsorBlock.</p>
+            <div className="grid grid-cols-2 gap-2">
+              {SPONSORBLOCK_CATEGORIES.map((category) => (
+                <div key={category.value} className="flex items-center space-x-2">
+                  <Checkbox
+                    id={`sponsorblock-${category.value}`}
+                    checked={formData.sponsorblock_categories.includes(category.value)}
+                    onCheckedChange={(checked) =>
+                      setFormData({
+                        ...formData,
+                        sponsorblock_categories: checked
+                          ? [...formData.sponsorblock_categories, category.value]
+                          : formData.sponsorblock_categories.filter((c) => c !== category.value),
+                      })
+                    }
+                  />
+                  <Label htmlFor={`sponsorblock-${category.value}`} className="text-sm font-normal cursor-pointer">
+                    {category.label}
+                  </Label>
+                </div>
+              ))}
+            </div>
+          </div>
           <div>
             <Label htmlFor="image">Image</Label>
             <Input
@@ -121,6 +253,26 @@ export function EditPodcastDialog({ podcast }: EditPodcastDialogProps) {
               }}
             />
           </div>
+          <div className="flex items-center space-x-2">
+            <Switch
+              id="episode_artwork"
+              checked={formData.episode_artwork}
+              onCheckedChange={(checked) => setFormData({ ...formData, episode_artwork: checked })}
+            />
+            <Label htmlFor="episode_artwork" className="cursor-pointer">
+              Use video thumbnails as episode artwork
+            </Label>
+          </div>
+          <div className="flex items-center space-x-2">
+            <Switch
+              id="use_upload_date"
+              checked={formData.use_upload_date}
+              onCheckedChange={(checked) => setFormData({ ...formData, use_upload_date: checked })}
+            />
+            <Label htmlFor="use_upload_date" className="cursor-pointer">
+              Publish new episodes at the video's YouTube upload date
+            </Label>
+          </div>
           <div className="flex justify-end gap-2">
             <Button variant="outline" onClick={() => setIsUpdateDialogOpen(false)}>
               Cancel
@@ -0,0 +1,417 @@
+import { Plus, Rss, X } from "lucide-react";
+import { Button } from "@/components/ui/button";
+import {
+  Dialog,
+  DialogContent,
+  DialogDescription,
+  DialogHeader,
+  DialogTitle,
+  DialogTrigger,
+} from "@/components/ui/dialog";
+import { Input } from "@/components/ui/input";
+import { Label } from "@/components/ui/label";
+import { Switch } from "@/components/ui/switch";
+import { Select, SelectContent, SelectItem, SelectTrigger, SelectValue } from "@/components/ui/select";
+import { useUpdatePodcast } from "@/lib/api/mutations";
+import { toast } from "sonner";
+import { useState, useEffect } from "react";
+import { PodcastsItunesTypeOptions, type PodcastsResponse } from "@/lib/pocketbase-types";
+
+// Apple Podcasts' categories and their subcategories.
+const CATEGORIES: Record<string, string[]> = {
+  Arts: ["Books", "Design", "Fashion & Beauty", "Food", "Performing Arts", "Visual Arts"],
+  Business: ["Careers", "Entrepreneurship", "Investing", "Management", "Marketing", "Non-Profit"],
+  Comedy: ["Comedy Interviews", "Improv", "Stand-Up"],
+  Education: ["Courses", "How To", "Language Learning", "Self-Improvement"],
+  Fiction: ["Comedy Fiction", "Drama", "Science Fiction"],
+  Government: [],
+  History: [],
+  "Health & Fitness": ["Alternative Health", "Fitness", "Medicine", "Mental Health", "Nutrition", "Sexuality"],
+  "Kids & Family": ["Education for Kids", "Parenting", "Pets & Animals", "Stories for Kids"],
+  Leisure: ["Animation & Manga", "Automotive", "Aviation", "Crafts", "Games", "Hobbies", "Home & Garden", "Video Games"],
+  Music: ["Music Commentary", "Music History", "Music Interviews"],
+  News: ["Business News", "Daily News", "Entertainment News", "News Commentary", "Politics", "Sports News", "Tech News"],
+  "Religion & Spirituality": ["Buddhism", "Christianity", "Hinduism", "Islam", "Judaism", "Religion", "Spirituality"],
+  Science: [
+    "Astronomy",
+    "Chemistry",
+    "Earth Sciences",
+    "Life Sciences",
+    "Mathematics",
+    "Natural Sciences",
+    "Nature",
+    "Physics",
+    "Social Sciences",
+  ],
+  "Society & Culture": ["Documentary", "Personal Journals", "Philosophy", "Places & Travel", "Relationships"],
+  Sports: [
+    "Baseball",
+    "Basketball",
+    "Cricket",
+    "Fantasy Sports",
+    "Football",
+    "Golf",
+    "Hockey",
+    "Rugby",
+    "Running",
+    "Soccer",
+    "Swimming",
+    "Tennis",
+    "Volleyball",
+    "Wilderness",
+    "Wrestling",
+  ],
+  Technology: [],
+  "True Crime": [],
+  "TV & Film": ["After Shows", "Film History", "Film Interviews", "Film Reviews", "TV Reviews"],
+};
+
+const MAX_CATEGORIES = 3;
+
+// Select items can't have an empty value.
+const NO_SUBCATEGORY = "none";
+const DEFAULT_TYPE = "default";
+
+type Category = { category: string; subcategory?: string };
+type Funding = { url: string; title: string };
+type Person = { name: string; role?: string; href?: string; img?: string };
+
+type FeedSettingsPodcast = PodcastsResponse<Category[], Funding[], Person[]>;
+
+interface FeedSettingsDialogProps {
+  podcast: PodcastsResponse;
+}
+
+function formFromPodcast(podcast: FeedSettingsPodcast) {
+  return {
+    categories: podcast?.categories?.length ? podcast.categories : [{ category: "Technology" }],
+    explicit: podcast?.explicit || false,
+    language: podcast?.language || "",
+    copyright: podcast?.copyright || "",
+    itunes_type: (podcast?.itunes_type || DEFAULT_TYPE) as string,
+    itunes_block: podcast?.itunes_block || false,
+    itunes_complete: podcast?.itunes_complete || false,
+    podcast_guid: podcast?.podcast_guid || "",
+    locked: podcast?.locked || false,
+    funding: podcast?.funding || [],
+    persons: podcast?.persons || [],
+  };
+}
+
+export function FeedSettingsDialog({ podcast }: FeedSettingsDialogProps) {
+  const feedPodcast = podcast as FeedSettingsPodcast;
+  const [formData, setFormData] = useState(formFromPodcast(feedPodcast));
+  const [isDialogOpen, setIsDialogOpen] = useState(false);
+  const updatePodcastMutation = useUpdatePodcast();
+
+  useEffect(() => {
+    if (podcast) {
+      setFormData(formFromPodcast(podcast as FeedSettingsPodcast));
+    }
+  }, [podcast]);
+
+  const setCategory = (index: number, category: Category) => {
+    setFormData({
+      ...formData,
+      categories: formData.categories.map((c, i) => (i === index ? category : c)),
+    });
+  };
+
+  const setFunding = (index: number, funding: Funding) => {
+    setFormData({ ...formData, funding: formData.funding.map((f, i) => (i === index ? funding : f)) });
+  };
+
+  const setPerson = (index: number, person: Person) => {
+    setFormData({ ...formData, persons: formData.persons.map((p, i) => (i === index ? person : p)) });
+  };
+
+  const handleSave = async () => {
+    if (!podcast) return;
+
+    const data: any = {
+      categories: formData.categories,
+      explicit: formData.explicit,
+      language: formData.language.trim(),
+      copyright: formData.copyright.trim(),
+      itunes_type: formData.itunes_type === DEFAULT_TYPE ? "" : formData.itunes_type,
+      itunes_block: formData.itunes_block,
+      itunes_complete: formData.itunes_complete,
+      podcast_guid: formData.podcast_guid.trim(),
+      locked: formData.locked,
+      funding: formData.funding.filter((f) => f.url.trim()),
+      persons: formData.persons.filter((p) => p.name.trim()),
+    };
+
+    try {
+      await updatePodcastMutation.mutateAsync({ id: podcast.id, data });
+      toast.success("Feed settings updated successfully!");
+      setIsDialogOpen(false);
+    } catch (error: any) {
+      toast.error(error?.response?.message || "Failed to update feed settings");
+    }
+  };
+
+  return (
+    <Dialog open={isDialogOpen} onOpenChange={setIsDialogOpen}>
+      <DialogTrigger asChild>
+        <Button variant="outline">
+          <Rss className="mr-2 h-4 w-4" />
+          Feed Settings
+        </Button>
+      </DialogTrigger>
+      <DialogContent className="max-w-2xl max-h-[90vh] overflow-y-auto">
+        <DialogHeader>
+          <DialogTitle className="flex items-center gap-2">
+            <Rss className="h-5 w-5" />
+            Feed Settings
+          </DialogTitle>
+          <DialogDescription>How your podcast is listed in Apple Podcasts and other apps.</DialogDescription>
+        </DialogHeader>
+        <div className="space-y-4">
+          <div>
+            <Label>Categories</Label>
+            <div className="space-y-2 mt-1">
+              {formData.categories.map((category, index) => (
+                <div key={index} className="flex gap-2">
+                  <Select
+                    value={category.category}
+                    onValueChange={(value) => setCategory(index, { category: value })}
+                  >
+                    <SelectTrigger className="flex-1">
+                      <SelectValue />
+                    </SelectTrigger>
+                    <SelectContent>
+                      {Object.keys(CATEGORIES).map((name) => (
+                        <SelectItem key={name} value={name}>
+                          {name}
+                        </SelectItem>
+                      ))}
+                    </SelectContent>
+                  </Select>
+                  <Select
+                    value={category.subcategory || NO_SUBCATEGORY}
+                    onValueChange={(value) =>
+                      setCategory(index, {
+                        category: category.category,
+                        subcategory: value === NO_SUBCATEGORY ? undefined : value,
+                      })
+                    }
+                    disabled={!CATEGORIES[category.category]?.length}
+                  >
+                    <SelectTrigger className="flex-1">
+                      <SelectValue />
+                    </SelectTrigger>
+                    <SelectContent>
+                      <SelectItem value={NO_SUBCATEGORY}>No subcategory</SelectItem>
+                      {(CATEGORIES[category.category] || []).map((name) => (
+                        <SelectItem key={name} value={name}>
+                          {name}
+                        </SelectItem>
+                      ))}
+                    </SelectContent>
+                  </Select>
+                  <Button
+                    variant="ghost"
+                    size="icon"
+                    disabled={formData.categories.length === 1}
+                    onClick={() =>
+                      setFormData({ ...formData, categories: formData.categories.filter((_, i) => i !== index) })
+                    }
+                  >
+                    <X className="h-4 w-4" />
+                  </Button>
+                </div>
+              ))}
+              {formData.categories.length < MAX_CATEGORIES && (
+                <Button
+                  variant="outline"
+                  size="sm"
+                  onClick={() =>
+                    setFormData({ ...formData, categories: [...formData.categories, { category: "Technology" }] })
+                  }
+                >
+                  <Plus className="mr-2 h-4 w-4" />
+                  Add Category
+                </Button>
+              )}
+            </div>
+          </div>
+          <div className="grid grid-cols-2 gap-4">
+            <div>
+              <Label htmlFor="language">Language</Label>
+              <Input
+                id="language"
+                value={formData.language}
+                onChange={(e) => setFormData({ ...formData, language: e.target.value })}
+                placeholder="en-us"
+              />
+            </div>
+            <div>
+              <Label htmlFor="itunes_type">Show Type</Label>
+              <Select
+                value={formData.itunes_type}
+                onValueChange={(value) => setFormData({ ...formData, itunes_type: value })}
+              >
+                <SelectTrigger id="itunes_type" className="w-full">
+                  <SelectValue />
+                </SelectTrigger>
+                <SelectContent>
+                  <SelectItem value={DEFAULT_TYPE}>Not set</SelectItem>
+                  <SelectItem value={PodcastsItunesTypeOptions.episodic}>Episodic, newest first</SelectItem>
+                  <SelectItem value={PodcastsItunesTypeOptions.serial}>Serial, oldest first</SelectItem>
+                </SelectContent>
+              </Select>
+            </div>
+          </div>
+          <div>
+            <Label htmlFor="copyright">Copyright</Label>
+            <Input
+              id="copyright"
+              value={formData.copyright}
+              onChange={(e) => setFormData({ ...formData, copyright: e.target.value })}
+              placeholder="© 2025 Your Name"
+            />
+          </div>
+          <div className="space-y-2">
+            <div className="flex items-center space-x-2">
+              <Switch
+                id="explicit"
+                checked={formData.explicit}
+                onCheckedChange={(checked) => setFormData({ ...formData, explicit: checked })}
+              />
+              <Label htmlFor="explicit" className="cursor-pointer">
+                Contains explicit content
+              </Label>
+            </div>
+            <div className="flex items-center space-x-2">
+              <Switch
+                id="itunes_complete"
+                checked={formData.itunes_complete}
+                onCheckedChange={(checked) => setFormData({ ...formData, itunes_complete: checked })}
+              />
+              <Label htmlFor="itunes_complete" className="cursor-pointer">
+                Complete, no more episodes will be added
+              </Label>
+            </div>
+            <div className="flex items-center space-x-2">
+              <Switch
+                id="itunes_block"
+                checked={formData.itunes_block}
+                onCheckedChange={(checked) => setFormData({ ...formData, itunes_block: checked })}
+              />
+              <Label htmlFor="itunes_block" className="cursor-pointer">
+                Hide from Apple Podcasts
+              </Label>
+            </div>
+            <div className="flex items-center space-x-2">
+              <Switch
+                id="locked"
+                checked={formData.locked}
+                onCheckedChange={(checked) => setFormData({ ...formData, locked: checked })}
+              />
+              <Label htmlFor="locked" className="cursor-pointer">
+                Lock the feed so other platforms can't import it
+              </Label>
+            </div>
+          </div>
+          <div>
+            <Label>Funding</Label>
+            <p className="text-sm text-muted-foreground mb-2">Links where listeners can support the show.</p>
+            <div className="space-y-2">
+              {formData.funding.map((funding, index) => (
+                <div key={index} className="flex gap-2">
+                  <Input
+                    type="url"
+                    value={funding.url}
+                    onChange={(e) => setFunding(index, { ...funding, url: e.target.value })}
+                    placeholder="https://example.com/donate"
+                  />
+                  <Input
+                    value={funding.title}
+                    maxLength={128}
+                    onChange={(e) => setFunding(index, { ...funding, title: e.target.value })}
+                    placeholder="Support the show"
+                  />
+                  <Button
+                    variant="ghost"
+                    size="icon"
+                    onClick={() => setFormData({ ...formData, funding: formData.funding.filter((_, i) => i !== index) })}
+                  >
+                    <X className="h-4 w-4" />
+                  </Button>
+                </div>
+              ))}
+              <Button
+                variant="outline"
+                size="sm"
+                onClick={() => setFormData({ ...formData, funding: [...formData.funding, { url: "", title: "" }] })}
+              >
+                <Plus className="mr-2 h-4 w-4" />
+                Add Link
+              </Button>
+            </div>
+          </div>
+          <div>
+            <Label>People</Label>
+            <p className="text-sm text-muted-foreground mb-2">Hosts and guests credited on the show.</p>
+            <div className="space-y-2">
+              {formData.persons.map((person, index) => (
+                <div key={index} className="flex gap-2">
+                  <Input
+                    value={person.name}
+                    onChange={(e) => setPerson(index, { ...person, name: e.target.value })}
+                    placeholder="Name"
+                  />
+                  <Input
+                    value={person.role || ""}
+                    onChange={(e) => setPerson(index, { ...person, role: e.target.value })}
+                    placeholder="host"
+                  />
+                  <Input
+                    type="url"
+                    value={person.href || ""}
+                    onChange={(e) => setPerson(index, { ...person, href: e.target.value })}
+                    placeholder="https://example.com"
+                  />
+                  <Button
+                    variant="ghost"
+                    size="icon"
+                    onClick={() => setFormData({ ...formData, persons: formData.persons.filter((_, i) => i !== index) })}
+                  >
+                    <X className="h-4 w-4" />
+                  </Button>
+                </div>
+              ))}
+              <Button
+                variant="outline"
+                size="sm"
+                onClick={() => setFormData({ ...formData, persons: [...formData.persons, { name: "", role: "host" }] })}
+              >
+                <Plus className="mr-2 h-4 w-4" />
+                Add Person
+              </Button>
+            </div>
+          </div>
+          <div>
+            <Label htmlFor="podcast_guid">Podcast GUID</Label>
+            <Input
+              id="podcast_guid"
+              value={formData.podcast_guid}
+              onChange={(e) => setFormData({ ...formData, podcast_guid: e.target.value })}
+              placeholder="Generated from the feed URL"
+            />
+            <p className="text-sm text-muted-foreground mt-1">Only set this when moving a podcast from another host.</p>
+          </div>
+          <div className="flex justify-end gap-2">
+            <Button variant="outline" onClick={() => setIsDialogOpen(false)}>
+              Cancel
+            </Button>
+            <Button onClick={handleSave} disabled={updatePodcastMutation.isPending}>
+              {updatePodcastMutation.isPending ? "Saving..." : "Save Settings"}
+            </Button>
+          </div>
+        </div>
+      </DialogContent>
+    </Dialog>
+  );
+}
@@ -1,6 +1,7 @@
 import { pb } from "@/lib/pocketbase";
 import { PodcastSubscribeButtons } from "./podcast-button";
 import { EditPodcastDialog } from "./edit-podcast-dialog";
+import { FeedSettingsDialog } from "./feed-settings-dialog";
 import { AddItemDialog } from "./add-item-dialog";
 import type { PodcastsResponse } from "@/lib/pocketbase-types";
 
@@ -50,6 +51,7 @@ export function PodcastHeader({ podcast, podcastUrl, podcastId, disabled = false
       </div>
       <div className="flex gap-2 self-start md:self-auto">
         <EditPodcastDialog podcast={podcast} />
+        <FeedSettingsDialog podcast={podcast} />
         <AddItemDialog podcastId={podcastId} />
       </div>
     </div>
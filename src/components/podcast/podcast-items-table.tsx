@@ -1,18 +1,21 @@
 import { Table, TableBody, TableCell, TableHead, TableHeader, TableRow } from "@/components/ui/table";
 import { Button } from "@/components/ui/button";
-import { LoaderCircle, MoreHorizontal, Youtube, Upload, AlertCircle, X } from "lucide-react";
+import { Badge } from "@/components/ui/badge";
+import { LoaderCircle, MoreHorizontal, Youtube, Upload, AlertCircle, X, Ban } from "lucide-react";
 import {
   DropdownMenu,
   DropdownMenuContent,
   DropdownMenuItem,
   DropdownMenuTrigger,
 } from "@/components/ui/dropdown-menu";
-import { useDeletePodcastItem } from "@/lib/api/mutations";
-import { formatDuration, formatFileSize } from "@/lib/utils";
+import { useCancelPodcastItem, useDeletePodcastItem } from "@/lib/api/mutations";
+import { formatDuration, formatFileSize, getNextAttempt } from "@/lib/utils";
 import type { ItemsResponse } from "@/lib/pocketbase-types";
-import { ItemsStatusOptions, ItemsTypeOptions } from "@/lib/pocketbase-types";
+import { ItemsPublicationOptions, ItemsStatusOptions, ItemsTypeOptions } from "@/lib/pocketbase-types";
 import type { ExpandItem } from "@/lib/api/api";
 import { pb } from "@/lib/pocketbase";
+import { EditItemDialog } from "./edit-item-dialog";
+import { useState } from "react";
 
 interface PodcastItemsTableProps {
   podcastItems: ItemsResponse<ExpandItem>[];
@@ -20,6 +23,8 @@ interface PodcastItemsTableProps {
 
 export function PodcastItemsTable({ podcastItems }: PodcastItemsTableProps) {
   const deleteItemMutation = useDeletePodcastItem();
+  const cancelItemMutation = useCancelPodcastItem();
+  const [editingItem, setEditingItem] = useState<ItemsResponse<ExpandItem> | null>(null);
 
   const handleDownload = (item: ItemsResponse<ExpandItem>) => {
     const expandData = item.type === ItemsTypeOptions.upload ? item.expand.upload : item.expand.download;
@@ -49,12 +54,24 @@ export function PodcastItemsTable({ podcastItems }: PodcastItemsTableProps) {
             {Array.isArray(podcastItems) &&
               podcastItems.map((item) => {
                 if (item.status === ItemsStatusOptions.CREATED) {
+                  const nextAttempt = getNextAttempt(item.next_attempt_at);
                   return (
                     <TableRow key={item.id}>
                       <TableCell colSpan={8} className="text-center">
-                        <div className="flex items-center justify-center py-2 bg-gray-100 rounded">
+                        <div className="relative flex items-center justify-center py-2 bg-gray-100 rounded">
                           <LoaderCircle className="h-5 w-5 sm:h-6 sm:w-6 animate-spin mr-2" />
-                          <span className="text-sm sm:text-base">Loading...</span>
+                          <span className="text-sm sm:text-base">
+                            {nextAttempt ? `Retrying after ${nextAttempt.toLocaleTimeString()}...` : "Loading..."}
+                          </span>
+                          <Button
+                            variant="ghost"
+                            size="sm"
+                            onClick={() => cancelItemMutation.mutate(item.id)}
+                            disabled={cancelItemMutation.isPending && cancelItemMutation.variables === item.id}
+                            className="absolute right-2 h-7 sm:h-8 text-xs sm:text-sm"
+                          >
+                            Cancel
+                          </Button>
                         </div>
                       </TableCell>
                     </TableRow>
@@ -87,6 +104,32 @@ export function PodcastItemsTable({ podcastItems }: PodcastItemsTableProps) {
                   );
                 }
 
+                if (item.status === ItemsStatusOptions.CANCELLED) {
+                  return (
+                    <TableRow key={item.id}>
+                      <TableCell colSpan={8}>
+                        <div className="flex items-center justify-between py-2 px-2 bg-gray-50 rounded">
+                          <div className="flex items-center gap-2 flex-1 min-w-0">
+                            <Ban className="h-4 w-4 sm:h-5 sm:w-5 text-muted-foreground flex-shrink-0" />
+                            <span className="text-muted-foreground text-xs sm:text-sm truncate">
+                              {item.title || item.url} was cancelled
+                            </span>
+                          </div>
+                          <Button
+                            variant="ghost"
+                            size="sm"
+                            onClick={() => deleteItemMutation.mutate(item.id)}
+                            className="h-7 w-7 sm:h-8 sm:w-8 p-0 hover:bg-gray-100 flex-shrink-0"
+                            title="Dismiss"
+                          >
+                            <X className="h-3 w-3 sm:h-4 sm:w-4" />
+                          </Button>
+                        </div>
+                      </TableCell>
+                    </TableRow>
+                  );
+                }
+
                 const isUpload = item.type === ItemsTypeOptions.upload;
                 const data = isUpload ? item.expand.upload : item.expand.download;
 
@@ -105,9 +148,9 @@ export function PodcastItemsTable({ podcastItems }: PodcastItemsTableProps) {
                     </TableCell>
                     <TableCell
                       className="max-w-[200px] sm:max-w-[350px] truncate text-xs sm:text-sm"
-                      title={data?.title}
+                      title={item.custom_title || data?.title}
                     >
-                      {data?.title}
+                      {item.custom_title || data?.title}
                     </TableCell>
                     <TableCell className="hidden sm:table-cell text-xs sm:text-sm">
                       {data?.duration ? formatDuration(data.duration) : "-"}
@@ -135,6 +178,16 @@ export function PodcastItemsTable({ podcastItems }: PodcastItemsTableProps) {
                     </TableCell>
                     <TableCell className="hidden md:table-cell text-xs sm:text-sm">
                       {new Date(item.created).toLocaleDateString()}
+                      {item.publication === ItemsPublicationOptions.draft && (
+                        <Badge variant="secondary" className="ml-2">
+                          Draft
+                        </Badge>
+                      )}
+                      {item.publication === ItemsPublicationOptions.scheduled && (
+                        <Badge variant="outline" className="ml-2" title={new Date(item.publish_at).toLocaleString()}>
+                          Scheduled {new Date(item.publish_at).toLocaleDateString()}
+                        </Badge>
+                      )}
                     </TableCell>
                     <TableCell>
                       <DropdownMenu>
@@ -145,6 +198,7 @@ export function PodcastItemsTable({ podcastItems }: PodcastItemsTableProps) {
                           </Button>
                         </DropdownMenuTrigger>
                         <DropdownMenuContent align="end">
+                          <DropdownMenuItem onClick={() => setEditingItem(item)}>Edit</DropdownMenuItem>
                           <DropdownMenuItem variant="destructive" onClick={() => deleteItemMutation.mutate(item.id)}>
                             Delete
                           </DropdownMenuItem>
@@ -158,6 +212,7 @@ export function PodcastItemsTable({ podcastItems }: PodcastItemsTableProps) {
           </TableBody>
         </Table>
       </div>
+      <EditItemDialog item={editingItem} open={!!editingItem} onOpenChange={(open) => !open && setEditingItem(null)} />
     </div>
   );
 }
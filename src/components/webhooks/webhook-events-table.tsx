@@ -47,6 +47,7 @@ export function WebhookEventsTable({ events }: WebhookEventsTableProps) {
       [WebhookEventsEventOptions.STARTED]: "bg-blue-100 text-blue-800",
       [WebhookEventsEventOptions.SUCCESS]: "bg-green-100 text-green-800",
       [WebhookEventsEventOptions.ERROR]: "bg-red-100 text-red-800",
+      [WebhookEventsEventOptions.CANCELLED]: "bg-amber-100 text-amber-800",
     };
 
     return <Badge className={colors[event]}>{event}</Badge>;
@@ -43,6 +43,11 @@ const EVENT_OPTIONS = [
     label: "Error",
     description: "Triggered when a job encounters an error",
   },
+  {
+    value: WebhooksEventsOptions.CANCELLED,
+    label: "Cancelled",
+    description: "Triggered when a job is cancelled",
+  },
 ];
 
 export function WebhookForm({ webhook, trigger }: WebhookFormProps) {
@@ -1,5 +1,5 @@
 import { pb } from "../pocketbase";
-import { Collections, ItemsStatusOptions, ItemsTypeOptions, JobsStatusOptions, type DownloadsResponse, type ItemsResponse, type JobsResponse, type MonthlyUsageResponse, type PodcastsRecord, type SubscriptionTiersResponse, type UploadsResponse, type WebhooksRecord } from "../pocketbase-types";
+import { Collections, ItemsStatusOptions, ItemsTypeOptions, JobsStatusOptions, type DownloadsResponse, type ItemsEpisodeTypeOptions, type ItemsRecord, type ItemsResponse, type JobsResponse, type MonthlyUsageResponse, type PodcastsRecord, type SubscriptionTiersResponse, type UploadsResponse, type WebhooksRecord } from "../pocketbase-types";
 import { getUserId } from "../utils";
 
 export async function addYoutubeUrls(urls: string[], podcastId: string) {
@@ -93,6 +93,19 @@ export async function deletePodcastItem(itemId: string) {
     return await pb.collection(Collections.Items).delete(itemId);
 }
 
+export async function cancelPodcastItem(itemId: string) {
+    return await pb.send(`/api/items/${itemId}/cancel`, { method: 'POST' });
+}
+
+export type ItemMetadata = Partial<Pick<ItemsRecord, "custom_title" | "show_notes" | "published_at" | "season" | "episode" | "explicit" | "publication" | "publish_at">> & {
+    episode_type?: ItemsEpisodeTypeOptions | ""
+    use_upload_date?: boolean
+}
+
+export async function updatePodcastItem(itemId: string, data: ItemMetadata) {
+    return await pb.send<ItemsResponse>(`/api/items/${itemId}`, { method: 'PATCH', body: data });
+}
+
 type ShareUrlResponse = {
     url: string;
 }
@@ -126,6 +139,10 @@ export async function createJobs(urls: string[]) {
     return await batch.send();
 }
 
+export async function cancelJob(jobId: string) {
+    return await pb.send(`/api/jobs/${jobId}/cancel`, { method: 'POST' });
+}
+
 export type ExpandJobs = {
     download: DownloadsResponse
 }
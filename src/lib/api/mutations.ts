@@ -1,5 +1,5 @@
 import { useMutation, useQueryClient } from "@tanstack/react-query";
-import { addAudioFiles, addYoutubeUrls, createCheckoutSession, createIssue, createJobs, createPodcast, createPortalSession, createWebhook, deleteAccount, deletePodcast, deletePodcastItem, deleteWebhook, generateAPIKey, revokeAPIKey, updatePodcast, updateUsername, updateWebhook, type AudioUpload, type SubscriptionType } from "./api";
+import { addAudioFiles, addYoutubeUrls, cancelJob, cancelPodcastItem, createCheckoutSession, createIssue, createJobs, createPodcast, createPortalSession, createWebhook, deleteAccount, deletePodcast, deletePodcastItem, deleteWebhook, generateAPIKey, revokeAPIKey, updatePodcast, updatePodcastItem, updateUsername, updateWebhook, type AudioUpload, type ItemMetadata, type SubscriptionType } from "./api";
 import { handleError } from "../utils";
 import type { PodcastsRecord, WebhooksRecord } from "../pocketbase-types";
 
@@ -41,6 +41,30 @@ export function useDeletePodcastItem() {
     })
 }
 
+export function useCancelPodcastItem() {
+    const queryClient = useQueryClient();
+
+    return useMutation({
+        mutationFn: (itemId: string) => cancelPodcastItem(itemId),
+        onError: handleError,
+        onSuccess: () => {
+            queryClient.invalidateQueries({ queryKey: ["items"] });
+        },
+    })
+}
+
+export function useUpdatePodcastItem() {
+    const queryClient = useQueryClient();
+
+    return useMutation({
+        mutationFn: ({ itemId, data }: { itemId: string, data: ItemMetadata }) => updatePodcastItem(itemId, data),
+        onError: handleError,
+        onSuccess: () => {
+            queryClient.invalidateQueries({ queryKey: ["items"] });
+        },
+    })
+}
+
 export function useCreatePodcast() {
     const queryClient = useQueryClient();
 
@@ -113,6 +137,18 @@ export function useCreateJobs() {
     })
 }
 
+export function useCancelJob() {
+    const queryClient = useQueryClient();
+
+    return useMutation({
+        mutationFn: (jobId: string) => cancelJob(jobId),
+        onError: handleError,
+        onSuccess: () => {
+            queryClient.invalidateQueries({ queryKey: ["jobs"] });
+        },
+    })
+}
+
 export function useCreateWebhook() {
     const queryClient = useQueryClient();
 
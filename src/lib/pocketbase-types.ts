@@ -14,12 +14,14 @@ export enum Collections {
 	Otps = "_otps",
 	Superusers = "_superusers",
 	ApiKeys = "api_keys",
+	DownloadLinks = "download_links",
 	Downloads = "downloads",
 	Issues = "issues",
 	Items = "items",
 	Jobs = "jobs",
 	MonthlyUsage = "monthly_usage",
 	Podcasts = "podcasts",
+	Proxies = "proxies",
 	Queue = "queue",
 	StripeCharges = "stripe_charges",
 	StripeCustomers = "stripe_customers",
@@ -169,16 +171,37 @@ export type ApiKeysRecord = {
 	user: RecordIdString
 }
 
-export type DownloadsRecord = {
+export type DownloadLinksRecord = {
+	created?: IsoDateString
+	expires_at: IsoDateString
+	id: string
+	job: RecordIdString
+	nonce: string
+	updated?: IsoDateString
+	used_at?: IsoDateString
+}
+
+export type DownloadsRecord<Tchapters = unknown, Tsegments = unknown> = {
+	artwork?: string
 	channel: string
+	chapters?: null | Tchapters
 	created?: IsoDateString
 	description?: string
 	duration?: number
+	fetching_queue?: RecordIdString
 	file?: string
 	id: string
+	original?: string
+	processing?: string
+	profile?: string
+	segments?: null | Tsegments
 	size?: number
+	tagged?: boolean
 	title: string
+	transcript?: string
+	transcript_language?: string
 	updated?: IsoDateString
+	upload_date?: IsoDateString
 	video_id: string
 }
 
@@ -200,13 +223,38 @@ export enum ItemsStatusOptions {
 	"CREATED" = "CREATED",
 	"SUCCESS" = "SUCCESS",
 	"ERROR" = "ERROR",
+	"CANCELLED" = "CANCELLED",
+}
+
+export enum ItemsEpisodeTypeOptions {
+	"full" = "full",
+	"trailer" = "trailer",
+	"bonus" = "bonus",
+}
+
+export enum ItemsPublicationOptions {
+	"draft" = "draft",
+	"scheduled" = "scheduled",
+	"published" = "published",
 }
 export type ItemsRecord = {
 	created?: IsoDateString
+	custom_title?: string
 	download?: RecordIdString
+	episode?: number
+	episode_type?: ItemsEpisodeTypeOptions
 	error?: string
+	explicit?: boolean
+	file?: string
 	id: string
+	next_attempt_at?: IsoDateString
 	podcast: RecordIdString
+	publication?: ItemsPublicationOptions
+	publish_at?: IsoDateString
+	published_at?: IsoDateString
+	season?: number
+	show_notes?: HTMLString
+	size?: number
 	status: ItemsStatusOptions
 	title?: string
 	type: ItemsTypeOptions
@@ -216,22 +264,40 @@ export type ItemsRecord = {
 	user: RecordIdString
 }
 
+export enum JobsAudioProfileOptions {
+	"speech-64k-mono-mp3" = "speech-64k-mono-mp3",
+	"music-192k-mp3" = "music-192k-mp3",
+	"opus-48k" = "opus-48k",
+	"aac-m4a" = "aac-m4a",
+}
+
+export enum JobsSpeedOptions {
+	"1.25" = "1.25",
+	"1.5" = "1.5",
+}
+
 export enum JobsStatusOptions {
 	"SUCCESS" = "SUCCESS",
 	"ERROR" = "ERROR",
 	"PROCESSING" = "PROCESSING",
 	"STARTED" = "STARTED",
 	"CREATED" = "CREATED",
+	"CANCELLED" = "CANCELLED",
 }
 export type JobsRecord = {
 	api_key?: RecordIdString
+	audio_profile?: JobsAudioProfileOptions
 	batch_id: string
 	created?: IsoDateString
 	download?: RecordIdString
 	error?: string
 	id: string
+	loudness_target?: number
+	next_attempt_at?: IsoDateString
+	speed?: JobsSpeedOptions
 	status: JobsStatusOptions
 	title?: string
+	trim_silence?: boolean
 	updated?: IsoDateString
 	url: string
 	user: RecordIdString
@@ -250,44 +316,125 @@ export type MonthlyUsageRecord = {
 	user: RecordIdString
 }
 
-export type PodcastsRecord = {
+export enum PodcastsAudioProfileOptions {
+	"speech-64k-mono-mp3" = "speech-64k-mono-mp3",
+	"music-192k-mp3" = "music-192k-mp3",
+	"opus-48k" = "opus-48k",
+	"aac-m4a" = "aac-m4a",
+}
+
+export enum PodcastsItunesTypeOptions {
+	"episodic" = "episodic",
+	"serial" = "serial",
+}
+
+export enum PodcastsSpeedOptions {
+	"1.25" = "1.25",
+	"1.5" = "1.5",
+}
+
+export enum PodcastsSponsorblockCategoriesOptions {
+	"sponsor" = "sponsor",
+	"selfpromo" = "selfpromo",
+	"interaction" = "interaction",
+	"intro" = "intro",
+	"outro" = "outro",
+	"preview" = "preview",
+	"music_offtopic" = "music_offtopic",
+	"filler" = "filler",
+}
+export type PodcastsRecord<Tcategories = unknown, Tfunding = unknown, Tpersons = unknown> = {
 	apple_url?: string
+	audio_profile?: PodcastsAudioProfileOptions
+	categories?: null | Tcategories
+	copyright?: string
 	created?: IsoDateString
 	description: string
+	disable_episode_artwork?: boolean
+	explicit?: boolean
+	feed_lease_expires_at?: IsoDateString
+	feed_version?: number
 	file?: string
+	funding?: null | Tfunding
 	id: string
 	image: string
+	itunes_block?: boolean
+	itunes_complete?: boolean
+	itunes_type?: PodcastsItunesTypeOptions
+	language?: string
+	locked?: boolean
+	loudness_target?: number
+	persons?: null | Tpersons
 	pocketcasts_url?: string
+	podcast_guid?: string
+	speed?: PodcastsSpeedOptions
+	sponsorblock_categories?: PodcastsSponsorblockCategoriesOptions[]
 	spotify_url?: string
 	title: string
+	trim_silence?: boolean
 	updated?: IsoDateString
+	use_upload_date?: boolean
 	user: RecordIdString
 	website?: string
 	youtube_url?: string
 }
 
+export type ProxiesRecord = {
+	consecutive_failures?: number
+	created?: IsoDateString
+	enabled?: boolean
+	failures?: number
+	id: string
+	label: string
+	last_error?: string
+	last_used?: IsoDateString
+	max_concurrency?: number
+	successes?: number
+	updated?: IsoDateString
+	url: string
+	weight?: number
+}
+
 export enum QueueCollectionOptions {
 	"jobs" = "jobs",
 	"items" = "items",
 }
 
+export enum QueueLastErrorKindOptions {
+	"transient" = "transient",
+	"permanent" = "permanent",
+	"proxy_blocked" = "proxy_blocked",
+	"rate_limited" = "rate_limited",
+}
+
 export enum QueueStatusOptions {
 	"PENDING" = "PENDING",
 	"PROCESSING" = "PROCESSING",
 	"FAILED" = "FAILED",
 	"COMPLETED" = "COMPLETED",
+	"CANCELLED" = "CANCELLED",
+	"WAITING" = "WAITING",
 }
 export type QueueRecord = {
+	callback_secret?: string
 	collection: QueueCollectionOptions
 	created?: IsoDateString
+	download?: RecordIdString
 	id: string
 	last_error?: string
+	last_error_kind?: QueueLastErrorKindOptions
 	last_proxy?: string
+	lease_expires_at?: IsoDateString
+	next_attempt_at?: IsoDateString
 	oxylab_job_id?: string
+	oxylab_started_at?: IsoDateString
+	priority?: number
+	proxy_failures?: number
 	record_id: string
 	retry_count?: number
 	status: QueueStatusOptions
 	updated?: IsoDateString
+	user?: RecordIdString
 	worker_id?: string
 }
 
@@ -392,6 +539,7 @@ export enum WebhookEventsEventOptions {
 	"SUCCESS" = "SUCCESS",
 	"STARTED" = "STARTED",
 	"CREATED" = "CREATED",
+	"CANCELLED" = "CANCELLED",
 }
 export type WebhookEventsRecord = {
 	api_key?: RecordIdString
@@ -410,6 +558,7 @@ export enum WebhooksEventsOptions {
 	"ERROR" = "ERROR",
 	"STARTED" = "STARTED",
 	"CREATED" = "CREATED",
+	"CANCELLED" = "CANCELLED",
 }
 export type WebhooksRecord = {
 	created?: IsoDateString
@@ -430,12 +579,14 @@ export type MfasResponse<Texpand = unknown> = Required<MfasRecord> & BaseSystemF
 export type OtpsResponse<Texpand = unknown> = Required<OtpsRecord> & BaseSystemFields<Texpand>
 export type SuperusersResponse<Texpand = unknown> = Required<SuperusersRecord> & AuthSystemFields<Texpand>
 export type ApiKeysResponse<Texpand = unknown> = Required<ApiKeysRecord> & BaseSystemFields<Texpand>
-export type DownloadsResponse<Texpand = unknown> = Required<DownloadsRecord> & BaseSystemFields<Texpand>
+export type DownloadLinksResponse<Texpand = unknown> = Required<DownloadLinksRecord> & BaseSystemFields<Texpand>
+export type DownloadsResponse<Tchapters = unknown, Tsegments = unknown, Texpand = unknown> = Required<DownloadsRecord<Tchapters, Tsegments>> & BaseSystemFields<Texpand>
 export type IssuesResponse<Texpand = unknown> = Required<IssuesRecord> & BaseSystemFields<Texpand>
 export type ItemsResponse<Texpand = unknown> = Required<ItemsRecord> & BaseSystemFields<Texpand>
 export type JobsResponse<Texpand = unknown> = Required<JobsRecord> & BaseSystemFields<Texpand>
 export type MonthlyUsageResponse<Texpand = unknown> = Required<MonthlyUsageRecord> & BaseSystemFields<Texpand>
-export type PodcastsResponse<Texpand = unknown> = Required<PodcastsRecord> & BaseSystemFields<Texpand>
+export type PodcastsResponse<Tcategories = unknown, Tfunding = unknown, Tpersons = unknown, Texpand = unknown> = Required<PodcastsRecord<Tcategories, Tfunding, Tpersons>> & BaseSystemFields<Texpand>
+export type ProxiesResponse<Texpand = unknown> = Required<ProxiesRecord> & BaseSystemFields<Texpand>
 export type QueueResponse<Texpand = unknown> = Required<QueueRecord> & BaseSystemFields<Texpand>
 export type StripeChargesResponse<Tmetadata = unknown, Texpand = unknown> = Required<StripeChargesRecord<Tmetadata>> & BaseSystemFields<Texpand>
 export type StripeCustomersResponse<Texpand = unknown> = Required<StripeCustomersRecord> & BaseSystemFields<Texpand>
@@ -457,12 +608,14 @@ export type CollectionRecords = {
 	_otps: OtpsRecord
 	_superusers: SuperusersRecord
 	api_keys: ApiKeysRecord
+	download_links: DownloadLinksRecord
 	downloads: DownloadsRecord
 	issues: IssuesRecord
 	items: ItemsRecord
 	jobs: JobsRecord
 	monthly_usage: MonthlyUsageRecord
 	podcasts: PodcastsRecord
+	proxies: ProxiesRecord
 	queue: QueueRecord
 	stripe_charges: StripeChargesRecord
 	stripe_customers: StripeCustomersRecord
@@ -483,12 +636,14 @@ export type CollectionResponses = {
 	_otps: OtpsResponse
 	_superusers: SuperusersResponse
 	api_keys: ApiKeysResponse
+	download_links: DownloadLinksResponse
 	downloads: DownloadsResponse
 	issues: IssuesResponse
 	items: ItemsResponse
 	jobs: JobsResponse
 	monthly_usage: MonthlyUsageResponse
 	podcasts: PodcastsResponse
+	proxies: ProxiesResponse
 	queue: QueueResponse
 	stripe_charges: StripeChargesResponse
 	stripe_customers: StripeCustomersResponse
@@ -512,12 +667,14 @@ export type TypedPocketBase = PocketBase & {
 	collection(idOrName: '_otps'): RecordService<OtpsResponse>
 	collection(idOrName: '_superusers'): RecordService<SuperusersResponse>
 	collection(idOrName: 'api_keys'): RecordService<ApiKeysResponse>
+	collection(idOrName: 'download_links'): RecordService<DownloadLinksResponse>
 	collection(idOrName: 'downloads'): RecordService<DownloadsResponse>
 	collection(idOrName: 'issues'): RecordService<IssuesResponse>
 	collection(idOrName: 'items'): RecordService<ItemsResponse>
 	collection(idOrName: 'jobs'): RecordService<JobsResponse>
 	collection(idOrName: 'monthly_usage'): RecordService<MonthlyUsageResponse>
 	collection(idOrName: 'podcasts'): RecordService<PodcastsResponse>
+	collection(idOrName: 'proxies'): RecordService<ProxiesResponse>
 	collection(idOrName: 'queue'): RecordService<QueueResponse>
 	collection(idOrName: 'stripe_charges'): RecordService<StripeChargesResponse>
 	collection(idOrName: 'stripe_customers'): RecordService<StripeCustomersResponse>
@@ -48,4 +48,11 @@ export const formatFileSize = (size: number) => {
   if (size < 1024 * 1024) return `${(size / 1024).toFixed(1)} KB`;
   if (size < 1024 * 1024 * 1024) return `${(size / (1024 * 1024)).toFixed(2)} MB`;
   return `${(size / (1024 * 1024 * 1024)).toFixed(2)} GB`;
-};
\ No newline at end of file
+};
+
+export function getNextAttempt(nextAttemptAt?: string): Date | null {
+  if (!nextAttemptAt) return null;
+  const date = new Date(nextAttemptAt);
+  if (isNaN(date.getTime()) || date.getTime() <= Date.now()) return null;
+  return date;
+}